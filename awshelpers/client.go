@@ -12,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	cf "github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/s3"
 )
 
 var (
@@ -58,3 +59,7 @@ func GetASGClient(region string) *autoscaling.AutoScaling {
 func GetEC2Client(region string) *ec2.EC2 {
 	return ec2.New(GetSession(region), config(region))
 }
+
+func GetS3Client(region string) *s3.S3 {
+	return s3.New(GetSession(region), config(region))
+}
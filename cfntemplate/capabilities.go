@@ -0,0 +1,77 @@
+package cfntemplate
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// namedIAMProperties are the properties which give an IAM resource a custom
+// name, and so require CAPABILITY_NAMED_IAM.
+var namedIAMProperties = map[string]string{
+	"AWS::IAM::Group":           "GroupName",
+	"AWS::IAM::InstanceProfile": "InstanceProfileName",
+	"AWS::IAM::ManagedPolicy":   "ManagedPolicyName",
+	"AWS::IAM::Role":            "RoleName",
+	"AWS::IAM::User":            "UserName",
+}
+
+// Capabilities works out which capabilities are needed to create or update a
+// stack from the template.
+//
+// CAPABILITY_IAM is always included. CAPABILITY_NAMED_IAM is added for IAM
+// resources with custom names, and CAPABILITY_AUTO_EXPAND for transforms,
+// macros, and nested stacks, whose templates may need expanding.
+func (t Template) Capabilities() []string {
+	caps := map[string]bool{
+		cloudformation.CapabilityCapabilityIam: true,
+	}
+
+	if _, ok := t["Transform"]; ok {
+		caps[cloudformation.CapabilityCapabilityAutoExpand] = true
+	}
+	if hasKey(t, "Fn::Transform") {
+		caps[cloudformation.CapabilityCapabilityAutoExpand] = true
+	}
+
+	for _, resource := range t.Resources() {
+		typ := ResourceType(resource)
+
+		if typ == "AWS::CloudFormation::Stack" {
+			caps[cloudformation.CapabilityCapabilityAutoExpand] = true
+		}
+		if prop, ok := namedIAMProperties[typ]; ok && Properties(resource)[prop] != nil {
+			caps[cloudformation.CapabilityCapabilityNamedIam] = true
+		}
+	}
+
+	res := []string{}
+	for c := range caps {
+		res = append(res, c)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// hasKey searches every mapping in the value for key.
+func hasKey(value interface{}, key string) bool {
+	switch v := value.(type) {
+	case Template:
+		return hasKey(map[string]interface{}(v), key)
+	case map[string]interface{}:
+		for k, child := range v {
+			if k == key || hasKey(child, key) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if hasKey(child, key) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
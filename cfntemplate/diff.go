@@ -0,0 +1,202 @@
+package cfntemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Kinds of change.
+const (
+	Added    = "added"
+	Removed  = "removed"
+	Modified = "modified"
+)
+
+// Change is a single difference between two templates.
+type Change struct {
+	Path string
+	Kind string
+	From interface{}
+	To   interface{}
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("%s: added %s", c.Path, formatValue(c.To))
+	case Removed:
+		return fmt.Sprintf("%s: removed %s", c.Path, formatValue(c.From))
+	}
+
+	return fmt.Sprintf("%s: %s -> %s", c.Path, formatValue(c.From), formatValue(c.To))
+}
+
+// Diff is the structural difference between two templates.
+type Diff struct {
+	// AddedResources and RemovedResources are the logical IDs of whole
+	// resources which were added or removed. They are not repeated in
+	// Changes.
+	AddedResources   []string
+	RemovedResources []string
+	Changes          []Change
+
+	from, to Template
+}
+
+// Compare finds the differences between two templates.
+func Compare(from, to Template) *Diff {
+	d := &Diff{from: from, to: to}
+
+	fromResources := from.Resources()
+	toResources := to.Resources()
+	for name := range toResources {
+		if _, ok := fromResources[name]; !ok {
+			d.AddedResources = append(d.AddedResources, name)
+		}
+	}
+	for name := range fromResources {
+		if _, ok := toResources[name]; !ok {
+			d.RemovedResources = append(d.RemovedResources, name)
+		}
+	}
+	sort.Strings(d.AddedResources)
+	sort.Strings(d.RemovedResources)
+
+	d.compare("", map[string]interface{}(from), map[string]interface{}(to))
+
+	return d
+}
+
+// Empty reports whether the templates are the same.
+func (d *Diff) Empty() bool {
+	return len(d.AddedResources) == 0 && len(d.RemovedResources) == 0 && len(d.Changes) == 0
+}
+
+// Write writes a human readable version of the diff.
+func (d *Diff) Write(w io.Writer) error {
+	if len(d.AddedResources) != 0 {
+		fmt.Fprintln(w, "Added resources:")
+		for _, name := range d.AddedResources {
+			fmt.Fprintf(w, "  + %s (%s)\n", name, ResourceType(d.to.Resources()[name]))
+		}
+	}
+
+	if len(d.RemovedResources) != 0 {
+		fmt.Fprintln(w, "Removed resources:")
+		for _, name := range d.RemovedResources {
+			fmt.Fprintf(w, "  - %s (%s)\n", name, ResourceType(d.from.Resources()[name]))
+		}
+	}
+
+	if len(d.Changes) != 0 {
+		fmt.Fprintln(w, "Changes:")
+		for _, c := range d.Changes {
+			if _, err := fmt.Fprintf(w, "  %s\n", c); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Diff) compare(path string, from, to interface{}) {
+	// Whole resources being added or removed are already listed
+	if d.isWholeResource(path) {
+		return
+	}
+
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		for _, k := range sortedKeys(fromMap, toMap) {
+			fromValue, inFrom := fromMap[k]
+			toValue, inTo := toMap[k]
+			childPath := joinPath(path, k)
+
+			switch {
+			case !inFrom:
+				if !d.isWholeResource(childPath) {
+					d.Changes = append(d.Changes, Change{Path: childPath, Kind: Added, To: toValue})
+				}
+			case !inTo:
+				if !d.isWholeResource(childPath) {
+					d.Changes = append(d.Changes, Change{Path: childPath, Kind: Removed, From: fromValue})
+				}
+			default:
+				d.compare(childPath, fromValue, toValue)
+			}
+		}
+		return
+	}
+
+	fromList, fromIsList := from.([]interface{})
+	toList, toIsList := to.([]interface{})
+	if fromIsList && toIsList && len(fromList) == len(toList) {
+		for i := range fromList {
+			d.compare(fmt.Sprintf("%s[%d]", path, i), fromList[i], toList[i])
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		d.Changes = append(d.Changes, Change{Path: path, Kind: Modified, From: from, To: to})
+	}
+}
+
+func (d *Diff) isWholeResource(path string) bool {
+	if !strings.HasPrefix(path, "Resources.") {
+		return false
+	}
+
+	name := strings.TrimPrefix(path, "Resources.")
+	for _, n := range append(d.AddedResources, d.RemovedResources...) {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedKeys(maps ...map[string]interface{}) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func formatValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return string(data)
+}
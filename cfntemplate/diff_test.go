@@ -0,0 +1,77 @@
+package cfntemplate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name         string
+		from, to     string
+		added        []string
+		removed      []string
+		changedPaths []string
+	}{
+		{
+			name: "same template in another format",
+			from: "Resources:\n  Listener:\n    Type: AWS::ElasticLoadBalancingV2::Listener\n    Properties:\n      Port: 80\n      LoadBalancerArn: !GetAtt LoadBalancer.Arn\n",
+			to:   `{"Resources": {"Listener": {"Properties": {"LoadBalancerArn": {"Fn::GetAtt": ["LoadBalancer", "Arn"]}, "Port": "80"}, "Type": "AWS::ElasticLoadBalancingV2::Listener"}}}`,
+		},
+		{
+			name:         "added and removed properties",
+			from:         "Resources:\n  A: {Type: AWS::SQS::Queue, Properties: {DelaySeconds: 5}}\n",
+			to:           "Resources:\n  A: {Type: AWS::SQS::Queue, Properties: {MessageRetentionPeriod: 60}}\n",
+			changedPaths: []string{"Resources.A.Properties.DelaySeconds", "Resources.A.Properties.MessageRetentionPeriod"},
+		},
+		{
+			name:    "added and removed resources",
+			from:    "Resources:\n  A: {Type: AWS::SNS::Topic}\n",
+			to:      "Resources:\n  B: {Type: AWS::SQS::Queue}\n",
+			added:   []string{"B"},
+			removed: []string{"A"},
+		},
+		{
+			name:         "changed property",
+			from:         "Resources:\n  A: {Type: AWS::SQS::Queue, Properties: {DelaySeconds: 5}}\n",
+			to:           "Resources:\n  A: {Type: AWS::SQS::Queue, Properties: {DelaySeconds: 10}}\n",
+			changedPaths: []string{"Resources.A.Properties.DelaySeconds"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, err := Parse(tt.from)
+			if err != nil {
+				t.Fatalf("Parse(from): %v", err)
+			}
+			to, err := Parse(tt.to)
+			if err != nil {
+				t.Fatalf("Parse(to): %v", err)
+			}
+
+			d := Compare(from, to)
+			if !reflect.DeepEqual(d.AddedResources, tt.added) {
+				t.Errorf("AddedResources = %v, want %v", d.AddedResources, tt.added)
+			}
+			if !reflect.DeepEqual(d.RemovedResources, tt.removed) {
+				t.Errorf("RemovedResources = %v, want %v", d.RemovedResources, tt.removed)
+			}
+
+			paths := []string{}
+			for _, c := range d.Changes {
+				paths = append(paths, c.Path)
+			}
+			if len(paths) == 0 {
+				paths = nil
+			}
+			if !reflect.DeepEqual(paths, tt.changedPaths) {
+				t.Errorf("changed paths = %v, want %v", paths, tt.changedPaths)
+			}
+
+			if empty := tt.added == nil && tt.removed == nil && tt.changedPaths == nil; d.Empty() != empty {
+				t.Errorf("Empty() = %v, want %v", d.Empty(), empty)
+			}
+		})
+	}
+}
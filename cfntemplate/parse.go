@@ -0,0 +1,174 @@
+// Package cfntemplate parses CloudFormation templates, written in either JSON
+// or YAML, into a common representation.
+package cfntemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/keyneston/cftool/helpers"
+	"gopkg.in/yaml.v3"
+)
+
+// Template is a parsed template. Mappings are map[string]interface{},
+// sequences are []interface{}, scalars are strings and nulls are nil.
+//
+// Short-form intrinsic functions (e.g. `!Ref Foo`) are expanded to their long
+// form (e.g. `{"Ref": "Foo"}`), so a template parses the same whichever form
+// it is written in.
+type Template map[string]interface{}
+
+// Parse parses a JSON or YAML template.
+func Parse(body string) (Template, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(body), doc); err != nil {
+		return nil, fmt.Errorf("parsing template: %v", err)
+	}
+
+	// An empty document
+	if len(doc.Content) == 0 {
+		return Template{}, nil
+	}
+
+	parsed, err := convert(doc.Content[0])
+	if err != nil {
+		return nil, err
+	}
+
+	t, ok := parsed.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("parsing template: expected a mapping at the top level")
+	}
+
+	return Template(t), nil
+}
+
+// Resources returns the Resources section of the template keyed by logical ID.
+func (t Template) Resources() map[string]map[string]interface{} {
+	resources := map[string]map[string]interface{}{}
+
+	section, _ := t["Resources"].(map[string]interface{})
+	for name, r := range section {
+		if resource, ok := r.(map[string]interface{}); ok {
+			resources[name] = resource
+		}
+	}
+
+	return resources
+}
+
+// ResourceType returns the Type of the resource.
+func ResourceType(resource map[string]interface{}) string {
+	typ, _ := resource["Type"].(string)
+	return typ
+}
+
+// Properties returns the Properties of the resource.
+func Properties(resource map[string]interface{}) map[string]interface{} {
+	props, _ := resource["Properties"].(map[string]interface{})
+	return props
+}
+
+func convert(n *yaml.Node) (interface{}, error) {
+	if n.Kind == yaml.AliasNode {
+		return convert(n.Alias)
+	}
+
+	value, err := convertUntagged(n)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := n.Tag
+	if !strings.HasPrefix(tag, "!") || strings.HasPrefix(tag, "!!") {
+		return value, nil
+	}
+
+	return expandShortForm(tag[1:], value), nil
+}
+
+func convertUntagged(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return convert(n.Content[0])
+	case yaml.MappingNode:
+		m := map[string]interface{}{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			if key.Kind == yaml.AliasNode {
+				key = key.Alias
+			}
+
+			value, err := convert(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[key.Value] = value
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		s := []interface{}{}
+		for _, item := range n.Content {
+			value, err := convert(item)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, value)
+		}
+		return s, nil
+	case yaml.ScalarNode:
+		if n.ShortTag() == "!!null" {
+			return nil, nil
+		}
+		return n.Value, nil
+	}
+
+	return nil, fmt.Errorf("parsing template: unexpected node at line %d", n.Line)
+}
+
+// expandShortForm converts a short-form intrinsic function into its long form.
+func expandShortForm(name string, value interface{}) interface{} {
+	switch name {
+	case "Ref", "Condition":
+		return map[string]interface{}{name: value}
+	case "GetAtt":
+		// The short form of GetAtt may be given as "Resource.Attribute"
+		if s, ok := value.(string); ok {
+			parts := strings.SplitN(s, ".", 2)
+			list := []interface{}{}
+			for _, p := range parts {
+				list = append(list, p)
+			}
+			value = list
+		}
+	}
+
+	return map[string]interface{}{"Fn::" + name: value}
+}
+
+// Canonical returns a normalized form of the template: JSON, with sorted keys
+// and no insignificant whitespace. Templates which differ only in formatting,
+// comments, key order, or whether they are JSON or YAML have the same
+// canonical form.
+func (t Template) Canonical() ([]byte, error) {
+	return json.Marshal(map[string]interface{}(t))
+}
+
+// CanonicalHash hashes the canonical form of the template body.
+func CanonicalHash(body string) (string, error) {
+	t, err := Parse(body)
+	if err != nil {
+		return "", err
+	}
+
+	canonical, err := t.Canonical()
+	if err != nil {
+		return "", err
+	}
+
+	return helpers.HashString(string(canonical)), nil
+}
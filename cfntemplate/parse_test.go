@@ -0,0 +1,176 @@
+package cfntemplate
+
+import (
+	"reflect"
+	"testing"
+)
+
+const baseYAML = `
+AWSTemplateFormatVersion: "2010-09-09"
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Sub "${AWS::StackName}-logs"
+  Listener:
+    Type: AWS::ElasticLoadBalancingV2::Listener
+    Properties:
+      Port: 80
+      LoadBalancerArn: !GetAtt LoadBalancer.Arn
+      DefaultActions:
+        - Type: forward
+          TargetGroupArn: !Ref TargetGroup
+`
+
+func TestCanonicalHash(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{
+			name: "JSON and YAML",
+			a:    baseYAML,
+			b: `{
+  "AWSTemplateFormatVersion": "2010-09-09",
+  "Resources": {
+    "Bucket": {
+      "Type": "AWS::S3::Bucket",
+      "Properties": {"BucketName": {"Fn::Sub": "${AWS::StackName}-logs"}}
+    },
+    "Listener": {
+      "Type": "AWS::ElasticLoadBalancingV2::Listener",
+      "Properties": {
+        "Port": 80,
+        "LoadBalancerArn": {"Fn::GetAtt": ["LoadBalancer", "Arn"]},
+        "DefaultActions": [{"Type": "forward", "TargetGroupArn": {"Ref": "TargetGroup"}}]
+      }
+    }
+  }
+}`,
+			same: true,
+		},
+		{
+			name: "short and long form GetAtt",
+			a:    `Value: !GetAtt LoadBalancer.Arn`,
+			b:    `Value: {"Fn::GetAtt": [LoadBalancer, Arn]}`,
+			same: true,
+		},
+		{
+			name: "short form GetAtt as a list",
+			a:    `Value: !GetAtt [LoadBalancer, Arn]`,
+			b:    `Value: !GetAtt LoadBalancer.Arn`,
+			same: true,
+		},
+		{
+			name: "short and long form Sub with variables",
+			a:    "Value: !Sub\n  - \"${Name}-logs\"\n  - Name: !Ref AWS::StackName\n",
+			b:    "Value:\n  Fn::Sub:\n    - \"${Name}-logs\"\n    - Name:\n        Ref: AWS::StackName\n",
+			same: true,
+		},
+		{
+			name: "key order",
+			a:    "A: 1\nB: 2\n",
+			b:    "B: 2\nA: 1\n",
+			same: true,
+		},
+		{
+			name: "comments and blank lines",
+			a:    "# The port\nPort: 80\n",
+			b:    "\nPort: 80 # still the port\n",
+			same: true,
+		},
+		{
+			name: "quoted and unquoted scalar",
+			a:    `Port: 80`,
+			b:    `Port: "80"`,
+			same: true,
+		},
+		{
+			name: "changed number",
+			a:    `Port: 80`,
+			b:    `Port: 8080`,
+			same: false,
+		},
+		{
+			name: "number with a different form",
+			a:    `Port: 80`,
+			b:    `Port: 80.0`,
+			same: false,
+		},
+		{
+			name: "changed boolean",
+			a:    `Enabled: true`,
+			b:    `Enabled: false`,
+			same: false,
+		},
+		{
+			name: "null and empty string",
+			a:    `Value: null`,
+			b:    `Value: ""`,
+			same: false,
+		},
+		{
+			name: "Ref and string",
+			a:    `Value: !Ref Name`,
+			b:    `Value: Name`,
+			same: false,
+		},
+		{
+			name: "changed Sub",
+			a:    `Value: !Sub "${AWS::StackName}-logs"`,
+			b:    `Value: !Sub "${AWS::StackName}-log"`,
+			same: false,
+		},
+		{
+			name: "list order",
+			a:    `Value: [a, b]`,
+			b:    `Value: [b, a]`,
+			same: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := CanonicalHash(tt.a)
+			if err != nil {
+				t.Fatalf("CanonicalHash(a): %v", err)
+			}
+			b, err := CanonicalHash(tt.b)
+			if err != nil {
+				t.Fatalf("CanonicalHash(b): %v", err)
+			}
+
+			if (a == b) != tt.same {
+				t.Errorf("hashes equal = %v, want %v", a == b, tt.same)
+			}
+		})
+	}
+}
+
+func TestParseShortForm(t *testing.T) {
+	tests := []struct {
+		body string
+		want interface{}
+	}{
+		{`V: !Ref Name`, map[string]interface{}{"Ref": "Name"}},
+		{`V: !Condition IsProd`, map[string]interface{}{"Condition": "IsProd"}},
+		{`V: !GetAtt A.B`, map[string]interface{}{"Fn::GetAtt": []interface{}{"A", "B"}}},
+		{`V: !GetAtt A.B.C`, map[string]interface{}{"Fn::GetAtt": []interface{}{"A", "B.C"}}},
+		{`V: !Join [",", [a, b]]`, map[string]interface{}{"Fn::Join": []interface{}{",", []interface{}{"a", "b"}}}},
+		{`V: !Base64 {"Fn::Sub": x}`, map[string]interface{}{"Fn::Base64": map[string]interface{}{"Fn::Sub": "x"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			parsed, err := Parse(tt.body)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			if got := parsed["V"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
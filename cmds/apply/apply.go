@@ -0,0 +1,206 @@
+package apply
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/google/subcommands"
+	"github.com/keyneston/cftool/awshelpers"
+	"github.com/keyneston/cftool/config"
+	"github.com/keyneston/cftool/helpers"
+	"github.com/keyneston/cftool/output"
+	"github.com/keyneston/cftool/plan"
+)
+
+// pollInterval is how often the stack is checked while waiting for the
+// changeset to finish executing.
+const pollInterval = 10 * time.Second
+
+type ApplyPlan struct {
+	General  *config.GeneralConfig
+	StacksDB *config.StacksDB
+
+	Timeout          time.Duration
+	PlanFile         string
+	AllowDestructive bool
+}
+
+func (*ApplyPlan) Name() string { return "apply" }
+func (*ApplyPlan) Synopsis() string {
+	return "Execute the changesets recorded in a plan"
+}
+
+func (*ApplyPlan) Usage() string {
+	return `apply [-plan plan.json]
+	Executes each changeset in the plan and waits for the stacks to finish updating
+
+	Before anything is executed the templates, parameters, and changesets are
+	checked against the plan. If anything has changed since the plan was
+	created, nothing is applied. Nor is anything applied if it would remove or
+	replace a protected resource, unless -allow-destructive is given or the
+	resource is listed in the stack's allow_destructive.`
+}
+
+func (r *ApplyPlan) SetFlags(f *flag.FlagSet) {
+	f.DurationVar(&r.Timeout, "t", time.Minute*30, "timeout for waiting for the stacks to update")
+	f.StringVar(&r.PlanFile, "plan", "plan.json", "name of the plan file to apply")
+	f.BoolVar(&r.AllowDestructive, "allow-destructive", false, "allow removing or replacing protected resources")
+}
+
+func (r *ApplyPlan) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if r.Timeout < pollInterval {
+		return helpers.Exitf("Invalid timeout set: %v", r.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	p, err := plan.LoadPlan(r.PlanFile)
+	if err != nil {
+		return helpers.Exitf("loading plan %q: %v", r.PlanFile, err)
+	}
+
+	if len(p.Stacks) == 0 {
+		log.Printf("Plan %q has nothing to apply", r.PlanFile)
+		return subcommands.ExitSuccess
+	}
+
+	if !r.verify(ctx, p) {
+		return helpers.Exitf("refusing to apply plan %q", r.PlanFile)
+	}
+
+	wg := &sync.WaitGroup{}
+	resultCh := make(chan ApplyEntry, len(p.Stacks))
+	wg.Add(len(p.Stacks))
+
+	for _, sp := range p.Stacks {
+		go r.executeChangeSet(ctx, wg, resultCh, sp)
+	}
+
+	wg.Wait()
+	close(resultCh)
+
+	exitCode := subcommands.ExitSuccess
+	results := []ApplyEntry{}
+	for result := range resultCh {
+		if result.Error != "" {
+			exitCode = subcommands.ExitFailure
+		}
+		results = append(results, result)
+	}
+
+	if err := output.New(r.General.Format).Print(os.Stdout, results); err != nil {
+		return helpers.ExitErr(err)
+	}
+
+	return exitCode
+}
+
+// verify checks every stack in the plan is unchanged since the plan was
+// created, logging the reasons for any that aren't. It returns false if any
+// stack failed verification.
+func (r *ApplyPlan) verify(ctx context.Context, p *plan.Plan) bool {
+	valid := true
+
+	for _, sp := range p.Stacks {
+		stack := r.StacksDB.FindByARN(sp.StackARN)
+		if stack == nil {
+			log.Printf("Refusing to apply %s: stack %q is not in the local config", sp.Name, sp.StackARN)
+			valid = false
+			continue
+		}
+
+		// Check the changes of the changeset which will be executed, not those
+		// recorded in the plan, which may be stale or edited.
+		changes, err := sp.Verify(ctx, stack)
+		errs := helpers.Errors(err)
+		if !r.AllowDestructive {
+			errs = append(errs, helpers.Errors(plan.CheckDestructive(changes, r.General.ProtectedTypes, stack))...)
+		}
+
+		for _, err := range errs {
+			log.Printf("Refusing to apply %s: %v", sp.Name, err)
+			valid = false
+		}
+	}
+
+	return valid
+}
+
+func (r *ApplyPlan) executeChangeSet(ctx context.Context, wg *sync.WaitGroup, results chan<- ApplyEntry, sp *plan.StackPlan) {
+	defer wg.Done()
+
+	entry := ApplyEntry{
+		Region:    sp.Region,
+		OurName:   sp.Name,
+		ChangeSet: sp.ChangeSetARN,
+	}
+	if err := r.execute(ctx, &entry); err != nil {
+		entry.Error = err.Error()
+	}
+
+	results <- entry
+}
+
+func (r *ApplyPlan) execute(ctx context.Context, entry *ApplyEntry) error {
+	a, err := arn.Parse(entry.ChangeSet)
+	if err != nil {
+		return err
+	}
+	entry.Region = a.Region
+
+	client := awshelpers.GetCloudFormationClient(a.Region)
+
+	changeSet, err := client.DescribeChangeSetWithContext(ctx, &cloudformation.DescribeChangeSetInput{
+		ChangeSetName: &entry.ChangeSet,
+	})
+	if err != nil {
+		return fmt.Errorf("DescribeChangeSet: %v", err)
+	}
+	entry.Stack = aws.StringValue(changeSet.StackName)
+
+	log.Printf("Executing changeset for %s", entry.Stack)
+	if _, err := client.ExecuteChangeSetWithContext(ctx, &cloudformation.ExecuteChangeSetInput{
+		ChangeSetName: &entry.ChangeSet,
+	}); err != nil {
+		return fmt.Errorf("ExecuteChangeSet: %v", err)
+	}
+
+	stackInput := &cloudformation.DescribeStacksInput{
+		StackName: changeSet.StackId,
+	}
+	waitErr := client.WaitUntilStackUpdateCompleteWithContext(ctx, stackInput,
+		request.WithWaiterDelay(request.ConstantWaiterDelay(pollInterval)),
+		request.WithWaiterMaxAttempts(int(r.Timeout/pollInterval)),
+	)
+
+	// Always try to fetch the final status, even if the waiter failed, so
+	// that the report shows where the stack ended up.
+	live, err := client.DescribeStacksWithContext(context.Background(), stackInput)
+	if err == nil && len(live.Stacks) > 0 {
+		entry.Status = aws.StringValue(live.Stacks[0].StackStatus)
+	}
+
+	if waitErr != nil {
+		return fmt.Errorf("waiting for stack to update: %v", waitErr)
+	}
+
+	return nil
+}
+
+type ApplyEntry struct {
+	Region    string `header:"aws region" json:"region"`
+	Stack     string `header:"stackname" json:"stack_name"`
+	OurName   string `header:"internal name" json:"name"`
+	Status    string `header:"stack status" json:"stack_status"`
+	ChangeSet string `json:"changeset"`
+	Error     string `header:"error" json:"error"`
+}
@@ -0,0 +1,213 @@
+package changesets
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/google/subcommands"
+	"github.com/keyneston/cftool/awshelpers"
+	"github.com/keyneston/cftool/config"
+	"github.com/keyneston/cftool/helpers"
+	"github.com/keyneston/cftool/output"
+)
+
+// Reasons a changeset is considered stale.
+const (
+	ReasonFailed     = "failed"
+	ReasonSuperseded = "superseded"
+	ReasonOld        = "old"
+)
+
+type ChangeSets struct {
+	General  *config.GeneralConfig
+	StacksDB *config.StacksDB
+
+	Delete    bool
+	DryRun    bool
+	OlderThan time.Duration
+}
+
+func (*ChangeSets) Name() string { return "changesets" }
+func (*ChangeSets) Synopsis() string {
+	return "List and clean up the changesets created by cftool"
+}
+
+func (*ChangeSets) Usage() string {
+	return `changesets [-delete | -dry-run] [-older-than 168h] [<filter1>, <filter2>...]
+	Lists the changesets cftool has created for the stacks, and why they are
+	stale if they are. With -delete the stale changesets are deleted, with
+	-dry-run the changesets that would be deleted are printed instead.
+
+	A changeset is stale if it failed, if it was created for a different
+	template than is currently on disk, or if it is older than -older-than.`
+}
+
+func (r *ChangeSets) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&r.Delete, "delete", false, "delete stale changesets")
+	f.BoolVar(&r.DryRun, "dry-run", false, "print what -delete would delete without deleting it; implies -delete")
+	f.DurationVar(&r.OlderThan, "older-than", time.Hour*24*7, "changesets older than this are stale; 0 disables")
+}
+
+func (r *ChangeSets) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if r.DryRun {
+		r.Delete = true
+	}
+
+	stacks, err := r.StacksDB.Filter(f.Args()...)
+	if err != nil {
+		return helpers.ExitErr(err)
+	}
+
+	wg := &sync.WaitGroup{}
+	results := make(chan []ChangeSetEntry, stacks.Len())
+	errCh := make(chan error, stacks.Len())
+	wg.Add(stacks.Len())
+	for _, s := range stacks.All {
+		go r.getEntries(ctx, wg, results, errCh, s)
+	}
+
+	wg.Wait()
+	close(results)
+	close(errCh)
+
+	exitCode := subcommands.ExitSuccess
+	for err := range errCh {
+		log.Printf("Error: %v", err)
+		exitCode = subcommands.ExitFailure
+	}
+
+	entries := []ChangeSetEntry{}
+	for result := range results {
+		entries = append(entries, result...)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Stack != entries[j].Stack {
+			return entries[i].Stack < entries[j].Stack
+		}
+		return entries[i].created.Before(entries[j].created)
+	})
+
+	if err := output.New(r.General.Format).Print(os.Stdout, entries); err != nil {
+		return helpers.ExitErr(err)
+	}
+
+	if !r.Delete {
+		return exitCode
+	}
+
+	for _, entry := range entries {
+		if entry.Stale == "" {
+			continue
+		}
+
+		if r.DryRun {
+			log.Printf("Would delete %s from %s (%s)", entry.ChangeSet, entry.Stack, entry.Stale)
+			continue
+		}
+
+		log.Printf("Deleting %s from %s (%s)", entry.ChangeSet, entry.Stack, entry.Stale)
+		client := awshelpers.GetCloudFormationClient(entry.Region)
+		if _, err := client.DeleteChangeSetWithContext(ctx, &cloudformation.DeleteChangeSetInput{
+			ChangeSetName: &entry.id,
+		}); err != nil {
+			log.Printf("Error: deleting %s: %v", entry.ChangeSet, err)
+			exitCode = subcommands.ExitFailure
+		}
+	}
+
+	return exitCode
+}
+
+func (r *ChangeSets) getEntries(ctx context.Context, wg *sync.WaitGroup, results chan<- []ChangeSetEntry, errCh chan<- error, s *config.StackConfig) {
+	defer wg.Done()
+
+	region, _ := s.Region()
+	awshelpers.Ratelimit(ctx, region, func() {
+		client, err := s.GetClient()
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		// If the disk template can't be read there is no current changeset,
+		// so nothing is considered superseded.
+		current, err := s.ChangeSetName()
+		if err != nil {
+			log.Printf("Warning: %s: %v", s.Name, err)
+		}
+		pattern := s.ChangeSetPattern()
+		stackName := s.StackName()
+
+		entries := []ChangeSetEntry{}
+		if err := client.ListChangeSetsPagesWithContext(ctx,
+			&cloudformation.ListChangeSetsInput{StackName: &stackName},
+			func(out *cloudformation.ListChangeSetsOutput, lastPage bool) bool {
+				for _, summary := range out.Summaries {
+					name := aws.StringValue(summary.ChangeSetName)
+					if !pattern.MatchString(name) {
+						continue
+					}
+
+					entry := ChangeSetEntry{
+						Region:          region,
+						Stack:           stackName,
+						OurName:         s.Name,
+						ChangeSet:       name,
+						Status:          aws.StringValue(summary.Status),
+						ExecutionStatus: aws.StringValue(summary.ExecutionStatus),
+						Created:         aws.TimeValue(summary.CreationTime).Format(time.RFC3339),
+						id:              aws.StringValue(summary.ChangeSetId),
+						created:         aws.TimeValue(summary.CreationTime),
+					}
+					entry.Stale = r.staleReason(entry, current)
+
+					entries = append(entries, entry)
+				}
+				return true
+			}); err != nil {
+			errCh <- fmt.Errorf("ListChangeSets %s: %v", s.Name, err)
+			return
+		}
+
+		results <- entries
+	})
+}
+
+// staleReason returns why the changeset is stale, or an empty string if it is
+// not.
+func (r *ChangeSets) staleReason(entry ChangeSetEntry, current string) string {
+	switch {
+	case entry.Status == cloudformation.ChangeSetStatusFailed:
+		return ReasonFailed
+	case entry.ExecutionStatus == cloudformation.ExecutionStatusObsolete:
+		return ReasonSuperseded
+	case current != "" && entry.ChangeSet != current:
+		return ReasonSuperseded
+	case r.OlderThan > 0 && time.Since(entry.created) > r.OlderThan:
+		return ReasonOld
+	}
+
+	return ""
+}
+
+type ChangeSetEntry struct {
+	Region          string `header:"aws region" json:"region"`
+	Stack           string `header:"stackname" json:"stack_name"`
+	OurName         string `header:"internal name" json:"name"`
+	ChangeSet       string `header:"changeset" json:"changeset"`
+	Status          string `header:"status" json:"status"`
+	ExecutionStatus string `header:"execution status" json:"execution_status"`
+	Created         string `header:"created" json:"created"`
+	Stale           string `header:"stale" json:"stale"`
+
+	id      string
+	created time.Time
+}
@@ -0,0 +1,73 @@
+package changesets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func TestStaleReason(t *testing.T) {
+	const current = "stack-current"
+
+	tests := []struct {
+		name      string
+		entry     ChangeSetEntry
+		current   string
+		olderThan time.Duration
+		want      string
+	}{
+		{
+			name:    "current",
+			entry:   ChangeSetEntry{ChangeSet: current, Status: cloudformation.ChangeSetStatusCreateComplete, created: time.Now()},
+			current: current,
+			want:    "",
+		},
+		{
+			name:    "failed",
+			entry:   ChangeSetEntry{ChangeSet: current, Status: cloudformation.ChangeSetStatusFailed, created: time.Now()},
+			current: current,
+			want:    ReasonFailed,
+		},
+		{
+			name:    "obsolete",
+			entry:   ChangeSetEntry{ChangeSet: current, ExecutionStatus: cloudformation.ExecutionStatusObsolete, created: time.Now()},
+			current: current,
+			want:    ReasonSuperseded,
+		},
+		{
+			name:    "other template",
+			entry:   ChangeSetEntry{ChangeSet: "stack-other", created: time.Now()},
+			current: current,
+			want:    ReasonSuperseded,
+		},
+		{
+			name:    "disk template unreadable",
+			entry:   ChangeSetEntry{ChangeSet: "stack-other", created: time.Now()},
+			current: "",
+			want:    "",
+		},
+		{
+			name:      "old",
+			entry:     ChangeSetEntry{ChangeSet: current, created: time.Now().Add(-48 * time.Hour)},
+			current:   current,
+			olderThan: 24 * time.Hour,
+			want:      ReasonOld,
+		},
+		{
+			name:    "old with -older-than disabled",
+			entry:   ChangeSetEntry{ChangeSet: current, created: time.Now().Add(-48 * time.Hour)},
+			current: current,
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ChangeSets{OlderThan: tt.olderThan}
+			if got := r.staleReason(tt.entry, tt.current); got != tt.want {
+				t.Errorf("staleReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,12 +8,14 @@ import (
 
 	"github.com/google/subcommands"
 	"github.com/keyneston/cftool/config"
+	"github.com/keyneston/cftool/helpers"
+	"github.com/keyneston/cftool/output"
 	"github.com/keyneston/tabslib"
 )
 
 type PrintConfig struct {
-	General  *config.GeneralConfig `json:"general"`
-	StacksDB *config.StacksDB      `json:"stacks"`
+	General  *config.GeneralConfig `json:"general" yaml:"general"`
+	StacksDB *config.StacksDB      `json:"stacks" yaml:"stacks"`
 }
 
 func (*PrintConfig) Name() string     { return "config" }
@@ -28,6 +30,14 @@ func (r *PrintConfig) SetFlags(f *flag.FlagSet) {
 }
 
 func (r *PrintConfig) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	printer := output.New(r.General.Format)
+	if !printer.IsTable() {
+		if err := printer.PrintValue(os.Stdout, r); err != nil {
+			return helpers.ExitErr(err)
+		}
+		return subcommands.ExitSuccess
+	}
+
 	io.WriteString(os.Stdout, tabslib.PrettyString(r))
 	io.WriteString(os.Stdout, "\n")
 	return subcommands.ExitSuccess
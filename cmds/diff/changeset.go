@@ -0,0 +1,60 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// changeSet is a calculated changeset along with the changesets of any nested
+// stacks it contains, keyed by their ID.
+type changeSet struct {
+	*cloudformation.DescribeChangeSetOutput
+
+	Nested map[string]*cloudformation.DescribeChangeSetOutput
+}
+
+// fetchNested follows the ChangeSetId of every nested stack change, fetching
+// the nested changesets recursively.
+func (c *changeSet) fetchNested(ctx context.Context, client *cloudformation.CloudFormation, out *cloudformation.DescribeChangeSetOutput) error {
+	if c.Nested == nil {
+		c.Nested = map[string]*cloudformation.DescribeChangeSetOutput{}
+	}
+
+	for _, change := range out.Changes {
+		if change.ResourceChange == nil || change.ResourceChange.ChangeSetId == nil {
+			continue
+		}
+
+		id := aws.StringValue(change.ResourceChange.ChangeSetId)
+		if _, ok := c.Nested[id]; ok {
+			continue
+		}
+
+		nested, err := client.DescribeChangeSetWithContext(ctx, &cloudformation.DescribeChangeSetInput{
+			ChangeSetName: &id,
+		})
+		if err != nil {
+			return fmt.Errorf("fetching nested changeset for %s: %v", aws.StringValue(change.ResourceChange.LogicalResourceId), err)
+		}
+		c.Nested[id] = nested
+
+		if err := c.fetchNested(ctx, client, nested); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nested returns the changeset of the nested stack the change belongs to, if
+// it has one.
+func (c *changeSet) nested(change *cloudformation.Change) *cloudformation.DescribeChangeSetOutput {
+	if change.ResourceChange == nil || change.ResourceChange.ChangeSetId == nil {
+		return nil
+	}
+
+	return c.Nested[aws.StringValue(change.ResourceChange.ChangeSetId)]
+}
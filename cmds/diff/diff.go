@@ -12,26 +12,29 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/google/subcommands"
+	"github.com/hashicorp/go-multierror"
 	"github.com/keyneston/cftool/awshelpers"
 	"github.com/keyneston/cftool/config"
 	"github.com/keyneston/cftool/helpers"
+	"github.com/keyneston/cftool/output"
+	"github.com/keyneston/cftool/plan"
 )
 
 const Update = "UPDATE"
 
-var staticCapabilities = []*string{
-	aws.String("CAPABILITY_IAM"),
-}
-
 type DiffStacks struct {
 	General  *config.GeneralConfig
 	StacksDB *config.StacksDB
 
 	Timeout    time.Duration
 	PlanOutput string
+	Detailed   bool
+
+	AllowDestructive bool
 }
 
 func (*DiffStacks) Name() string { return "diff" }
@@ -48,6 +51,8 @@ func (r *DiffStacks) SetFlags(f *flag.FlagSet) {
 	f.DurationVar(&r.Timeout, "t", time.Second*60, "timeout for waiting for results")
 	f.StringVar(&r.PlanOutput, "o", "plan.json", "name of file to output the plan ids to")
 	f.StringVar(&r.PlanOutput, "plan", "plan.json", "name of file to output the plan ids to")
+	f.BoolVar(&r.Detailed, "v", false, "show replacement, scope, and property details of each change")
+	f.BoolVar(&r.AllowDestructive, "allow-destructive", false, "allow removing or replacing protected resources")
 }
 
 func (r *DiffStacks) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -63,16 +68,23 @@ func (r *DiffStacks) Execute(ctx context.Context, f *flag.FlagSet, _ ...interfac
 		return subcommands.ExitFailure
 	}
 
+	printer := output.New(r.General.Format)
+	if err := printer.Check([]ChangeEntry{}); err != nil {
+		return helpers.ExitErr(err)
+	}
+
 	stacks, err := r.StacksDB.Filter(f.Args()...)
 	if err != nil {
 		return helpers.ExitErr(err)
 	}
 
-	changeSets := []string{}
+	changeSets := map[string]*config.StackConfig{}
+	// The hash of the live template each changeset was created against
+	liveHashes := map[string]string{}
 
 	for _, s := range stacks.All {
 		log.Printf("Diffing: %s", s.Name)
-		id, err := r.createChangeSet(s)
+		id, liveHash, err := r.createChangeSet(ctx, s)
 		if err != nil {
 			return helpers.ExitErr(err)
 		}
@@ -82,23 +94,79 @@ func (r *DiffStacks) Execute(ctx context.Context, f *flag.FlagSet, _ ...interfac
 			continue
 		}
 
-		changeSets = append(changeSets, id)
+		changeSets[id] = s
+		liveHashes[id] = liveHash
 	}
 
-	if err := r.getResults(ctx, changeSets); err != nil {
-		return subcommands.ExitFailure
+	results, err := r.getResults(ctx, changeSets)
+	failed := err != nil
+	for _, err := range helpers.Errors(err) {
+		log.Printf("Error: %v", err)
 	}
 
+	changes := []ChangeEntry{}
+	stackPlans := []*plan.StackPlan{}
+	destructive := false
+	for id, result := range results {
+		entries := createChanges(result)
+		changes = append(changes, entries...)
+
+		sp, err := plan.NewStackPlan(changeSets[id], liveHashes[id], result.DescribeChangeSetOutput, planChanges(entries))
+		if err != nil {
+			return helpers.ExitErr(err)
+		}
+		stackPlans = append(stackPlans, sp)
+
+		if r.AllowDestructive {
+			continue
+		}
+		for _, err := range helpers.Errors(plan.CheckDestructive(sp.Changes, r.General.ProtectedTypes, changeSets[id])) {
+			log.Printf("Error: %s: %v", sp.Name, err)
+			destructive = true
+		}
+	}
+
+	// Create the plan before printing, as printing colourises the entries.
+	if !destructive && !failed {
+		if _, err := plan.CreatePlan(r.PlanOutput, stackPlans...); err != nil {
+			return helpers.Exitf("writing plan %q: %v", r.PlanOutput, err)
+		}
+	}
+
+	if r.Detailed {
+		details := []*changeSet{}
+		for _, result := range results {
+			details = append(details, result)
+		}
+		err = printDetails(printer, details)
+	} else {
+		err = printChanges(printer, changes)
+	}
+	if err != nil {
+		return helpers.ExitErr(err)
+	}
+
+	if failed {
+		return helpers.Exitf("not writing plan, some changesets failed")
+	}
+	if destructive {
+		return helpers.Exitf("not writing plan, pass -allow-destructive or add the resources to allow_destructive to continue")
+	}
+	log.Printf("Plan written to %q", r.PlanOutput)
+
 	return subcommands.ExitSuccess
 }
 
-func (r *DiffStacks) getResults(ctx context.Context, ids []string) error {
-	errCh := make(chan error, len(ids))
-	resultCh := make(chan *cloudformation.DescribeChangeSetOutput, len(ids))
+// getResults waits for each of the changesets to finish calculating, and
+// returns the calculated changesets keyed by their ID. Changesets with no
+// changes are left out; any other failure is returned as an error.
+func (r *DiffStacks) getResults(ctx context.Context, changeSets map[string]*config.StackConfig) (map[string]*changeSet, error) {
+	errCh := make(chan error, len(changeSets))
+	resultCh := make(chan *changeSet, len(changeSets))
 	wg := &sync.WaitGroup{}
-	wg.Add(len(ids))
+	wg.Add(len(changeSets))
 
-	for _, id := range ids {
+	for id := range changeSets {
 		go r.waitForResult(ctx, wg, errCh, resultCh, id)
 	}
 
@@ -107,17 +175,17 @@ func (r *DiffStacks) getResults(ctx context.Context, ids []string) error {
 	close(errCh)
 	close(resultCh)
 
+	errs := &multierror.Error{}
 	for err := range errCh {
-		log.Printf("Error: %v", err)
+		errs = multierror.Append(errs, err)
 	}
 
-	changes := []ChangeEntry{}
+	results := map[string]*changeSet{}
 	for result := range resultCh {
-		changes = append(changes, createChanges(result)...)
+		results[aws.StringValue(result.ChangeSetId)] = result
 	}
 
-	printChanges(changes)
-	return nil
+	return results, errs.ErrorOrNil()
 }
 
 type logger struct{}
@@ -126,7 +194,7 @@ func (logger) Log(stuff ...interface{}) {
 	log.Printf("Logging: %#v", stuff)
 }
 
-func (r *DiffStacks) waitForResult(ctx context.Context, wg *sync.WaitGroup, errCh chan<- error, results chan<- *cloudformation.DescribeChangeSetOutput, id string) {
+func (r *DiffStacks) waitForResult(ctx context.Context, wg *sync.WaitGroup, errCh chan<- error, results chan<- *changeSet, id string) {
 	defer wg.Done()
 
 	a, err := arn.Parse(id)
@@ -146,8 +214,17 @@ func (r *DiffStacks) waitForResult(ctx context.Context, wg *sync.WaitGroup, errC
 		request.WithWaiterMaxAttempts(int(r.Timeout.Seconds())),
 		request.WithWaiterLogger(logger{}),
 	); err != nil {
-		err = fmt.Errorf("Error fetching changeset (may be due to no changes?): %v", err)
-		errCh <- err
+		// A changeset without any changes fails, which isn't a problem
+		failed, derr := client.DescribeChangeSetWithContext(ctx, input)
+		if derr == nil && isNoChanges(failed) {
+			log.Printf("No changes in %s", aws.StringValue(failed.StackName))
+			return
+		}
+
+		if derr == nil && failed.StatusReason != nil {
+			err = fmt.Errorf("%s", aws.StringValue(failed.StatusReason))
+		}
+		errCh <- fmt.Errorf("changeset %s failed: %v", id, err)
 		return
 	}
 
@@ -158,73 +235,172 @@ func (r *DiffStacks) waitForResult(ctx context.Context, wg *sync.WaitGroup, errC
 		return
 	}
 
-	results <- output
+	result := &changeSet{DescribeChangeSetOutput: output}
+	if err := result.fetchNested(ctx, client, output); err != nil {
+		errCh <- err
+		return
+	}
+
+	results <- result
 }
 
-func (r *DiffStacks) createChangeSet(s *config.StackConfig) (string, error) {
-	template, err := s.GetDiskTemplate()
+// createChangeSet creates the changeset for the stack, returning its ID and
+// the hash of the live template it was created against. The ID is empty if
+// nothing has changed.
+func (r *DiffStacks) createChangeSet(ctx context.Context, s *config.StackConfig) (string, string, error) {
+	templateHash, err := s.GetDiskTemplateHash()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	templateHash, err := s.GetDiskTemplateHash()
+	liveTemplate, err := s.GetLiveTemplate()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	liveHash, err := s.GetLiveTemplateHash()
+	liveParams, err := s.GetLiveParams()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
+	if err := s.RecordHistory(liveTemplate, liveParams); err != nil {
+		log.Printf("Warning: %s: unable to record template history: %v", s.Name, err)
+	}
+	liveHash := s.TemplateHash(liveTemplate)
+	changedParams := s.ChangedParamsFrom(liveParams)
 
-	// TODO: also check if any parameters change
-	if templateHash == liveHash {
-		return "", nil
+	if templateHash == liveHash && len(changedParams) == 0 {
+		return "", "", nil
 	}
 
 	stackName := s.StackName()
-	name, err := changesetName(s)
+	name, err := s.ChangeSetName()
+	if err != nil {
+		return "", "", err
+	}
+
+	region, err := s.Region()
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	client := awshelpers.GetCloudFormationClient(region)
+
+	if id, err := r.existingChangeSet(client, stackName, name); err != nil || id != "" {
+		return id, liveHash, err
+	}
+
+	capabilities, err := s.GetCapabilities()
+	if err != nil {
+		return "", "", err
 	}
 
 	changeSetInput := &cloudformation.CreateChangeSetInput{
 		ChangeSetType: aws.String(Update),
-		Capabilities:  staticCapabilities,
+		Capabilities:  aws.StringSlice(capabilities),
 		ChangeSetName: &name,
 		StackName:     &stackName,
-		TemplateBody:  &template,
-		Parameters:    s.AWSParams(),
+		Parameters:    s.AWSParams(liveParams),
+
+		IncludeNestedStacks: aws.Bool(true),
 	}
-	if err := changeSetInput.Validate(); err != nil {
-		return "", err
+
+	if templateHash == liveHash {
+		// Only the parameters have changed, so reuse the live template
+		log.Printf("Parameters changed for %s: %v", s.Name, strings.Join(changedParams, ", "))
+		changeSetInput.UsePreviousTemplate = aws.Bool(true)
+	} else if err := r.setTemplate(ctx, s, region, changeSetInput); err != nil {
+		return "", "", err
 	}
 
-	region, err := s.Region()
-	if err != nil {
-		return "", err
+	if err := changeSetInput.Validate(); err != nil {
+		return "", "", err
 	}
 
-	client := awshelpers.GetCloudFormationClient(region)
 	res, err := client.CreateChangeSet(changeSetInput)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	if res.Id == nil {
-		return "", fmt.Errorf("No changeset ID returned from AWS")
+		return "", "", fmt.Errorf("No changeset ID returned from AWS")
 	}
-	return *res.Id, nil
+	return *res.Id, liveHash, nil
 }
 
-func changesetName(s *config.StackConfig) (string, error) {
-	diskHash, err := s.GetDiskTemplateHash()
+// setTemplate sets the disk template on the changeset. Templates that are too
+// large to send directly, or all templates if configured, are uploaded to the
+// template bucket and passed by URL.
+func (r *DiffStacks) setTemplate(ctx context.Context, s *config.StackConfig, region string, input *cloudformation.CreateChangeSetInput) error {
+	template, err := s.GetDiskTemplate()
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	name := fmt.Sprintf("%s-%s", s.Name, diskHash)
-	// TODO: use regexp to conform to `[a-zA-Z][-a-zA-Z0-9]*`
-	name = strings.ReplaceAll(name, ":", "-")
-	name = strings.ReplaceAll(name, "_", "-")
+	tooLarge := len(template) > config.MaxTemplateBodySize
+	if !tooLarge && !r.General.AlwaysUploadTemplates {
+		input.TemplateBody = &template
+		return nil
+	}
+
+	bucket := r.General.GetTemplateBucket(region)
+	if bucket == nil {
+		if tooLarge {
+			return fmt.Errorf("template %q is %d bytes, over the %d byte limit; set template_bucket to upload it to S3",
+				s.GetDiskTemplateLocation(), len(template), config.MaxTemplateBodySize)
+		}
+
+		return fmt.Errorf("always_upload_templates is set but there is no template_bucket for %s", region)
+	}
+
+	url, err := bucket.Upload(ctx, template)
+	if err != nil {
+		return err
+	}
+	input.TemplateURL = &url
+
+	return nil
+}
+
+// isNoChanges reports whether the changeset failed only because there was
+// nothing to change.
+func isNoChanges(out *cloudformation.DescribeChangeSetOutput) bool {
+	reason := aws.StringValue(out.StatusReason)
+
+	return aws.StringValue(out.Status) == cloudformation.ChangeSetStatusFailed &&
+		(strings.Contains(reason, "didn't contain changes") || strings.Contains(reason, "No updates are to be performed"))
+}
+
+// existingChangeSet looks for a changeset which has already been created with
+// this name. If it is usable its ID is returned. If it failed, or can no longer
+// be executed, it is deleted so that it can be recreated.
+func (r *DiffStacks) existingChangeSet(client *cloudformation.CloudFormation, stackName, name string) (string, error) {
+	existing, err := client.DescribeChangeSet(&cloudformation.DescribeChangeSetInput{
+		StackName:     &stackName,
+		ChangeSetName: &name,
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == cloudformation.ErrCodeChangeSetNotFoundException {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("DescribeChangeSet: %v", err)
+	}
+
+	status := aws.StringValue(existing.Status)
+	executionStatus := aws.StringValue(existing.ExecutionStatus)
+
+	switch {
+	case status == cloudformation.ChangeSetStatusCreatePending,
+		status == cloudformation.ChangeSetStatusCreateInProgress:
+		log.Printf("Changeset %s is still being created, reusing it", name)
+		return aws.StringValue(existing.ChangeSetId), nil
+	case status == cloudformation.ChangeSetStatusCreateComplete &&
+		executionStatus == cloudformation.ExecutionStatusAvailable:
+		log.Printf("Reusing existing changeset %s", name)
+		return aws.StringValue(existing.ChangeSetId), nil
+	}
+
+	log.Printf("Replacing changeset %s (status: %s, execution status: %s)", name, status, executionStatus)
+	if _, err := client.DeleteChangeSet(&cloudformation.DeleteChangeSetInput{
+		ChangeSetName: existing.ChangeSetId,
+	}); err != nil {
+		return "", fmt.Errorf("DeleteChangeSet: %v", err)
+	}
 
-	return name, nil
+	return "", nil
 }
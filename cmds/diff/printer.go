@@ -1,19 +1,47 @@
 package diff
 
 import (
+	"fmt"
 	"os"
 	"sort"
+	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/fatih/color"
-	"github.com/lensesio/tableprinter"
+	"github.com/keyneston/cftool/output"
+	"github.com/keyneston/cftool/plan"
 )
 
 type ChangeEntry struct {
-	Stack  string `header:"Stack"`
-	Action string `header:"Action"`
-	Type   string `header:"Type"`
-	Name   string `header:"Resource Name"`
+	Stack  string `header:"Stack" json:"stack"`
+	Action string `header:"Action" json:"action"`
+	Type   string `header:"Type" json:"type"`
+	Name   string `header:"Resource Name" json:"logical_id"`
+
+	Replacement string `json:"replacement"`
+	// Nested is the path of logical IDs of the nested stacks the resource is
+	// in, or empty if it is in the top level stack.
+	Nested string `json:"nested_stack"`
+}
+
+// DetailEntry is a single ResourceChangeDetail of a change, used when printing
+// the detailed view.
+type DetailEntry struct {
+	// Stack is only shown outside of the table, where the entries aren't
+	// grouped under each stack.
+	Stack              string `json:"stack"`
+	Action             string `header:"Action" json:"action"`
+	Name               string `header:"Resource Name" json:"logical_id"`
+	Type               string `header:"Type" json:"type"`
+	Replacement        string `header:"Replacement" json:"replacement"`
+	Scope              string `header:"Scope" json:"scope"`
+	Target             string `header:"Target" json:"target"`
+	RequiresRecreation string `header:"Requires Recreation" json:"requires_recreation"`
+	ChangeSource       string `header:"Change Source" json:"change_source"`
+	CausingEntity      string `header:"Causing Entity" json:"causing_entity"`
+	// Nested is the path of the nested stacks the resource is in.
+	Nested string `json:"nested_stack"`
 }
 
 var (
@@ -22,28 +50,87 @@ var (
 	green = color.New(color.FgGreen).SprintFunc()
 )
 
-func printChanges(changes []ChangeEntry) {
-	sort.Slice(changes, func(i, j int) bool {
-		return (changes[j].Stack > changes[i].Stack) || (changes[j].Name > changes[i].Name)
+func printChanges(printer *output.Printer, changes []ChangeEntry) error {
+	// Stable, so that nested changes stay under their parent
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Stack < changes[j].Stack
 	})
 
-	for i, change := range changes {
-		switch change.Action {
-		case "Remove":
-			changes[i].Action = red(change.Action)
-		case "Modify":
-			changes[i].Action = blue(change.Action)
-		case "Add":
-			changes[i].Action = green(change.Action)
+	if printer.IsTable() {
+		for i, change := range changes {
+			changes[i].Action = colorAction(change.Action)
+			changes[i].Name = indent(change.Nested) + change.Name
 		}
 	}
 
-	printer := tableprinter.New(os.Stdout)
+	return printer.Print(os.Stdout, changes)
+}
+
+// printDetails prints a table of every change detail, grouped by stack. Other
+// formats get a single list, with the stack in each entry.
+func printDetails(printer *output.Printer, results []*changeSet) error {
+	sort.Slice(results, func(i, j int) bool {
+		return getAWSString(results[i].StackName) < getAWSString(results[j].StackName)
+	})
 
-	printer.Print(changes)
+	if !printer.IsTable() {
+		all := []DetailEntry{}
+		for _, result := range results {
+			all = append(all, createDetails(result)...)
+		}
+		return printer.Print(os.Stdout, all)
+	}
+
+	for _, result := range results {
+		details := createDetails(result)
+		for i, detail := range details {
+			details[i].Action = colorAction(detail.Action)
+			details[i].Name = indent(detail.Nested) + detail.Name
+			if detail.Replacement == "True" || detail.Replacement == "Conditional" {
+				details[i].Replacement = red(detail.Replacement)
+			}
+		}
+
+		fmt.Fprintf(os.Stdout, "\nStack: %s\n", getAWSString(result.StackName))
+		if err := printer.Print(os.Stdout, details); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func createChanges(out *cloudformation.DescribeChangeSetOutput) []ChangeEntry {
+// indent returns the prefix which places a resource in the tree of nested
+// stacks.
+func indent(nested string) string {
+	if nested == "" {
+		return ""
+	}
+
+	depth := strings.Count(nested, "/") + 1
+	return strings.Repeat("  ", depth-1) + "└─ "
+}
+
+func colorAction(action string) string {
+	switch action {
+	case "Remove":
+		return red(action)
+	case "Modify":
+		return blue(action)
+	case "Add":
+		return green(action)
+	}
+
+	return action
+}
+
+func createChanges(cs *changeSet) []ChangeEntry {
+	return createNestedChanges(cs, cs.DescribeChangeSetOutput, getAWSString(cs.StackName), "")
+}
+
+// createNestedChanges creates the entries for out, with the entries of each
+// nested stack following the change to the nested stack itself.
+func createNestedChanges(cs *changeSet, out *cloudformation.DescribeChangeSetOutput, stack, nested string) []ChangeEntry {
 	entries := []ChangeEntry{}
 
 	for _, change := range out.Changes {
@@ -55,26 +142,111 @@ func createChanges(out *cloudformation.DescribeChangeSetOutput) []ChangeEntry {
 
 		switch *change.Type {
 		case "Resource":
-			entry = fromResourceChange(out, change)
+			entry = fromResourceChange(stack, change)
 		}
+		entry.Nested = nested
 
 		entries = append(entries, entry)
+
+		if child := cs.nested(change); child != nil {
+			entries = append(entries, createNestedChanges(cs, child, stack, joinNested(nested, entry.Name))...)
+		}
 	}
 
 	return entries
 }
 
-func fromResourceChange(out *cloudformation.DescribeChangeSetOutput, c *cloudformation.Change) ChangeEntry {
+func joinNested(parent, child string) string {
+	if parent == "" {
+		return child
+	}
+
+	return parent + "/" + child
+}
+
+func fromResourceChange(stack string, c *cloudformation.Change) ChangeEntry {
 	entry := ChangeEntry{}
 
-	entry.Stack = getAWSString(out.StackName)
+	entry.Stack = stack
 	entry.Action = getAWSString(c.ResourceChange.Action)
 	entry.Name = getAWSString(c.ResourceChange.LogicalResourceId)
 	entry.Type = getAWSString(c.ResourceChange.ResourceType)
+	entry.Replacement = getAWSString(c.ResourceChange.Replacement)
 
 	return entry
 }
 
+// createDetails creates a DetailEntry for each ResourceChangeDetail in the
+// changeset. Changes without any details, such as additions, get a single
+// entry.
+func createDetails(cs *changeSet) []DetailEntry {
+	return createNestedDetails(cs, cs.DescribeChangeSetOutput, "")
+}
+
+func createNestedDetails(cs *changeSet, out *cloudformation.DescribeChangeSetOutput, nested string) []DetailEntry {
+	entries := []DetailEntry{}
+
+	for _, change := range out.Changes {
+		if getAWSString(change.Type) != "Resource" || change.ResourceChange == nil {
+			continue
+		}
+		rc := change.ResourceChange
+
+		base := DetailEntry{
+			Stack:       getAWSString(cs.StackName),
+			Nested:      nested,
+			Action:      getAWSString(rc.Action),
+			Name:        getAWSString(rc.LogicalResourceId),
+			Type:        getAWSString(rc.ResourceType),
+			Replacement: getAWSString(rc.Replacement),
+			Scope:       strings.Join(aws.StringValueSlice(rc.Scope), ", "),
+		}
+
+		if len(rc.Details) == 0 {
+			entries = append(entries, base)
+		}
+
+		for _, detail := range rc.Details {
+			entry := base
+			entry.ChangeSource = getAWSString(detail.ChangeSource)
+			entry.CausingEntity = getAWSString(detail.CausingEntity)
+
+			if detail.Target != nil {
+				entry.Target = getAWSString(detail.Target.Attribute)
+				if name := getAWSString(detail.Target.Name); name != "" {
+					entry.Target += "." + name
+				}
+				entry.RequiresRecreation = getAWSString(detail.Target.RequiresRecreation)
+			}
+
+			entries = append(entries, entry)
+		}
+
+		if child := cs.nested(change); child != nil {
+			entries = append(entries, createNestedDetails(cs, child, joinNested(nested, getAWSString(rc.LogicalResourceId)))...)
+		}
+	}
+
+	return entries
+}
+
+// planChanges converts the entries into the form recorded in the plan.
+func planChanges(entries []ChangeEntry) []plan.Change {
+	changes := []plan.Change{}
+
+	for _, entry := range entries {
+		changes = append(changes, plan.Change{
+			Action:      entry.Action,
+			Type:        entry.Type,
+			LogicalID:   entry.Name,
+			Replacement: entry.Replacement,
+			NestedStack: entry.Nested,
+		})
+	}
+
+	return changes
+}
+
 func getAWSString(in *string) string {
 	if in == nil {
 		return ""
@@ -3,13 +3,17 @@ package difftemplate
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/google/subcommands"
+	"github.com/keyneston/cftool/cfntemplate"
 	"github.com/keyneston/cftool/config"
 	"github.com/pmezard/go-difflib/difflib"
 )
@@ -18,55 +22,111 @@ type DiffTemplate struct {
 	General  *config.GeneralConfig
 	StacksDB *config.StacksDB
 	Context  uint
+
+	Structural bool
+	Summary    bool
+	SideBySide bool
+	Color      string
+	Against    string
+	Stage      string
 }
 
+// Exit codes, following diff(1).
+const (
+	ExitSame      = subcommands.ExitSuccess
+	ExitDifferent = subcommands.ExitStatus(1)
+	ExitError     = subcommands.ExitStatus(2)
+)
+
 func (*DiffTemplate) Name() string { return "diff-template" }
 func (*DiffTemplate) Synopsis() string {
 	return "Create and print a diff of the live template and the on disk template"
 }
 
 func (*DiffTemplate) Usage() string {
-	return `diff-template [<filter1>, <filter2>...]
-	Provides a unix diff of the live template and the local disk template.`
+	return `diff-template [-structural] [-summary] [-y] [-color auto] [-against <hash|timestamp>] [<filter1>, <filter2>...]
+	Provides a unix diff of the live template and the local disk template.
+
+	With -structural both templates are parsed, and the differences are
+	listed by path instead, ignoring formatting, key order, and whether the
+	template is JSON or YAML.
+
+	With -summary one line is printed per stack saying whether it changed.
+	With -y the diff is printed side by side, live on the left and disk on the
+	right.
+
+	With -stage processed the disk template is compared to the live template
+	after transforms and macros have been expanded.
+
+	With -against the disk template is compared to a previous version of the
+	live template, picked by hash or timestamp from "cftool history".
+
+	Exits 0 if the templates are the same, 1 if any differ, and 2 on error.`
 }
 
 func (r *DiffTemplate) SetFlags(f *flag.FlagSet) {
 	f.UintVar(&r.Context, "c", 3, "Number of lines of context; defaults 3")
+	f.BoolVar(&r.Structural, "structural", false, "compare the parsed templates rather than the text")
+	f.BoolVar(&r.Summary, "summary", false, "print one line per stack instead of the full diff")
+	f.BoolVar(&r.SideBySide, "y", false, "print the diff side by side, sized to the terminal")
+	f.StringVar(&r.Color, "color", "auto", "colour the diff: auto, always, or never")
+	f.StringVar(&r.Stage, "stage", "", "stage of the live template to diff against: original or processed; defaults to the stack's template_stage, or original")
+	f.StringVar(&r.Against, "against", "", "diff against the version from the history with this hash or timestamp, instead of the live template")
 }
 
 func (r *DiffTemplate) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if err := setColor(r.Color); err != nil {
+		log.Printf("Error: %v", err)
+		return ExitError
+	}
+
 	stacks, err := r.StacksDB.Filter(f.Args()...)
 	if err != nil {
 		log.Printf("Error: %v", err)
-		return subcommands.ExitFailure
+		return ExitError
+	}
+
+	makeDiff := r.makeDiff
+	if r.Structural {
+		makeDiff = r.makeStructuralDiff
 	}
 
+	exitCode := ExitSame
 	for _, s := range stacks.All {
 		log.Printf("Diffing: %s", s.Name)
-		if err := r.makeDiff(s); err != nil {
-			log.Printf("Error: %v", err)
-			return subcommands.ExitFailure
+
+		changed, err := makeDiff(s)
+		if err != nil {
+			log.Printf("Error: %s: %v", s.Name, err)
+			exitCode = ExitError
+			continue
+		}
+
+		if changed && exitCode == ExitSame {
+			exitCode = ExitDifferent
 		}
 	}
 
-	return subcommands.ExitSuccess
+	return exitCode
 }
 
-func (r *DiffTemplate) makeDiff(s *config.StackConfig) error {
+// makeDiff writes a unified diff of the live and disk templates, and reports
+// whether they differ.
+func (r *DiffTemplate) makeDiff(s *config.StackConfig) (bool, error) {
 	diskTemplate, err := s.GetDiskTemplate()
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	liveTemplate, err := s.GetLiveTemplate()
+	liveTemplate, fromFile, fromDate, err := r.getFrom(s)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	ud := difflib.UnifiedDiff{
 		A:        strings.SplitAfter(liveTemplate, "\n"),
-		FromFile: filepath.Join("cloudformation", filepath.Base(s.Source)),
-		FromDate: "unknown", // TODO: get this date
+		FromFile: fromFile,
+		FromDate: fromDate,
 
 		B:      strings.SplitAfter(diskTemplate, "\n"),
 		ToFile: s.GetDiskTemplateLocation(),
@@ -76,9 +136,156 @@ func (r *DiffTemplate) makeDiff(s *config.StackConfig) error {
 		Eol:     "\n",
 	}
 
-	if err := difflib.WriteUnifiedDiff(os.Stdout, ud); err != nil {
-		return err
+	added, removed := countChanges(ud.A, ud.B)
+	changed := added != 0 || removed != 0
+
+	if r.Summary {
+		if changed {
+			fmt.Fprintf(os.Stdout, "%s: changed, +%d -%d lines\n", s.Name, added, removed)
+		} else {
+			fmt.Fprintf(os.Stdout, "%s: unchanged\n", s.Name)
+		}
+		return changed, nil
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	if r.SideBySide {
+		return true, writeSideBySide(os.Stdout, ud)
+	}
+
+	return true, writeColorDiff(os.Stdout, ud)
+}
+
+// getFrom returns the template to diff the disk template against, along with
+// the name and date to label it with. This is the live template, unless
+// -against picks a version from the history.
+func (r *DiffTemplate) getFrom(s *config.StackConfig) (template, file, date string, err error) {
+	region, err := s.Region()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if r.Against != "" {
+		entry, err := s.FindHistory(r.Against)
+		if err != nil {
+			return "", "", "", err
+		}
+
+		file = filepath.Join("history", region, s.StackName()+"@"+entry.Timestamp())
+		return entry.Template, file, entry.FetchedAt.Format(time.RFC3339), nil
+	}
+
+	stage := r.Stage
+	if stage == "" {
+		stage = s.TemplateStage
+	}
+	stage, err = config.ParseTemplateStage(stage)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	template, err = s.GetLiveTemplateStage(stage)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	live, err := s.GetLive()
+	if err != nil {
+		return "", "", "", err
+	}
+	if len(live.Stacks) == 0 {
+		return "", "", "", fmt.Errorf("stack %q not found", s.StackName())
+	}
+	cur := live.Stacks[0]
+	date = liveDate(cur)
+
+	// Only the original is recorded, so the history can be compared to the
+	// disk template.
+	if stage == cloudformation.TemplateStageOriginal {
+		if err := s.RecordHistory(template, config.StackParams(cur)); err != nil {
+			log.Printf("Warning: %s: unable to record template history: %v", s.Name, err)
+		}
+	}
+
+	file = filepath.Join("cloudformation", region, s.StackName())
+	if stage != cloudformation.TemplateStageOriginal {
+		file += "@" + strings.ToLower(stage)
+	}
+
+	return template, file, date, nil
+}
+
+// liveDate returns when the live stack was last updated, or created if it
+// has never been updated.
+func liveDate(cur *cloudformation.Stack) string {
+	updated := cur.LastUpdatedTime
+	if updated == nil {
+		updated = cur.CreationTime
+	}
+
+	return aws.TimeValue(updated).Format(time.RFC3339)
+}
+
+// countChanges counts the lines added and removed going from a to b.
+func countChanges(a, b []string) (added, removed int) {
+	matcher := difflib.NewMatcher(a, b)
+
+	for _, op := range matcher.GetOpCodes() {
+		switch op.Tag {
+		case 'r':
+			removed += op.I2 - op.I1
+			added += op.J2 - op.J1
+		case 'd':
+			removed += op.I2 - op.I1
+		case 'i':
+			added += op.J2 - op.J1
+		}
+	}
+
+	return added, removed
+}
+
+// makeStructuralDiff writes the structural differences between the live and
+// disk templates, and reports whether there are any.
+func (r *DiffTemplate) makeStructuralDiff(s *config.StackConfig) (bool, error) {
+	diskBody, err := s.GetDiskTemplate()
+	if err != nil {
+		return false, err
+	}
+	diskTemplate, err := cfntemplate.Parse(diskBody)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", s.GetDiskTemplateLocation(), err)
+	}
+
+	liveBody, fromFile, _, err := r.getFrom(s)
+	if err != nil {
+		return false, err
+	}
+	liveTemplate, err := cfntemplate.Parse(liveBody)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", fromFile, err)
+	}
+
+	diff := cfntemplate.Compare(liveTemplate, diskTemplate)
+	changed := !diff.Empty()
+
+	if r.Summary {
+		if changed {
+			fmt.Fprintf(os.Stdout, "%s: changed, +%d -%d resources, %d changes\n",
+				s.Name, len(diff.AddedResources), len(diff.RemovedResources), len(diff.Changes))
+		} else {
+			fmt.Fprintf(os.Stdout, "%s: unchanged\n", s.Name)
+		}
+		return changed, nil
+	}
+
+	if !changed {
+		return false, nil
 	}
 
-	return nil
+	fmt.Fprintf(os.Stdout, "--- %s\n+++ %s\n", fromFile, s.GetDiskTemplateLocation())
+	return true, diff.Write(os.Stdout)
 }
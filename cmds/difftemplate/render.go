@@ -0,0 +1,143 @@
+package difftemplate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-runewidth"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// defaultWidth is used for the side by side view when the width of the
+// terminal can't be found.
+const defaultWidth = 160
+
+var (
+	added   = color.New(color.FgGreen).SprintFunc()
+	removed = color.New(color.FgRed).SprintFunc()
+	header  = color.New(color.Bold).SprintFunc()
+	hunk    = color.New(color.FgCyan).SprintFunc()
+)
+
+// setColor sets whether output is coloured. "auto" leaves it to color, which
+// only colours output to a terminal.
+func setColor(mode string) error {
+	switch mode {
+	case "auto":
+	case "always":
+		color.NoColor = false
+	case "never":
+		color.NoColor = true
+	default:
+		return fmt.Errorf("invalid -color %q, must be one of auto, always, or never", mode)
+	}
+
+	return nil
+}
+
+// writeColorDiff writes the unified diff, colouring the added and removed
+// lines.
+func writeColorDiff(w io.Writer, ud difflib.UnifiedDiff) error {
+	diff, err := difflib.GetUnifiedDiffString(ud)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			line = header(line)
+		case strings.HasPrefix(line, "@@"):
+			line = hunk(line)
+		case strings.HasPrefix(line, "+"):
+			line = added(line)
+		case strings.HasPrefix(line, "-"):
+			line = removed(line)
+		}
+
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeSideBySide writes the diff as two columns, live on the left and disk on
+// the right, sized to fit the terminal.
+func writeSideBySide(w io.Writer, ud difflib.UnifiedDiff) error {
+	// Leave room for the marker between the columns
+	colWidth := (terminalWidth() - 3) / 2
+
+	fmt.Fprintf(w, "%s %s\n", header(pad(ud.FromFile+" "+ud.FromDate, colWidth)+" "), header(ud.ToFile+" "+ud.ToDate))
+
+	matcher := difflib.NewMatcher(ud.A, ud.B)
+	for i, group := range matcher.GetGroupedOpCodes(ud.Context) {
+		if i > 0 {
+			fmt.Fprintln(w, hunk(strings.Repeat("-", colWidth*2+3)))
+		}
+
+		for _, op := range group {
+			a := ud.A[op.I1:op.I2]
+			b := ud.B[op.J1:op.J2]
+
+			for j := 0; j < len(a) || j < len(b); j++ {
+				left, right := "", ""
+				if j < len(a) {
+					left = pad(trimEol(a[j]), colWidth)
+				} else {
+					left = pad("", colWidth)
+				}
+				if j < len(b) {
+					right = trimEol(b[j])
+				}
+
+				var err error
+				switch {
+				case op.Tag == 'e':
+					_, err = fmt.Fprintf(w, "%s   %s\n", left, right)
+				case j >= len(a):
+					_, err = fmt.Fprintf(w, "%s > %s\n", left, added(right))
+				case j >= len(b):
+					_, err = fmt.Fprintf(w, "%s <\n", removed(left))
+				default:
+					_, err = fmt.Fprintf(w, "%s | %s\n", removed(left), added(right))
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// pad truncates or pads the line to exactly width columns.
+func pad(line string, width int) string {
+	line = strings.ReplaceAll(line, "\t", "    ")
+	line = runewidth.Truncate(line, width, "")
+	return runewidth.FillRight(line, width)
+}
+
+func trimEol(line string) string {
+	return strings.TrimRight(line, "\r\n")
+}
+
+// terminalWidth finds the width of the terminal, falling back to $COLUMNS and
+// then defaultWidth.
+func terminalWidth() int {
+	if cols := ttyWidth(); cols > 0 {
+		return cols
+	}
+
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		return cols
+	}
+
+	return defaultWidth
+}
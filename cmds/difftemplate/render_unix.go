@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package difftemplate
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// ttyWidth returns the width of the terminal stdout is connected to, or 0 if
+// it isn't a terminal.
+func ttyWidth() int {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+
+	return int(ws.Col)
+}
@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package difftemplate
+
+// ttyWidth isn't supported on Windows, so terminalWidth always falls back to
+// $COLUMNS or defaultWidth.
+func ttyWidth() int {
+	return 0
+}
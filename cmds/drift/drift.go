@@ -0,0 +1,159 @@
+package drift
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/google/subcommands"
+	"github.com/keyneston/cftool/awshelpers"
+	"github.com/keyneston/cftool/config"
+	"github.com/keyneston/cftool/helpers"
+	"github.com/keyneston/cftool/output"
+)
+
+type Drift struct {
+	General  *config.GeneralConfig
+	StacksDB *config.StacksDB
+
+	Detect       bool
+	DriftTimeout time.Duration
+}
+
+func (*Drift) Name() string { return "drift" }
+func (*Drift) Synopsis() string {
+	return "List the drifted resources of the stacks and how they differ"
+}
+
+func (*Drift) Usage() string {
+	return `drift [-detect] [<filter1>, <filter2>...]
+	Lists each resource which has drifted from its template, with one row
+	per property that differs, as found by the last drift detection. With
+	-detect drift detection is run on each stack first.
+
+	Drift matching a drift_ignore rule in the config is not listed.
+`
+}
+
+func (r *Drift) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&r.Detect, "detect", false, "run drift detection on each stack first")
+	f.DurationVar(&r.DriftTimeout, "drift-timeout", 5*time.Minute, "how long to wait for drift detection")
+}
+
+func (r *Drift) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	stacks, err := r.StacksDB.Filter(f.Args()...)
+	if err != nil {
+		return helpers.ExitErr(err)
+	}
+
+	wg := &sync.WaitGroup{}
+	results := make(chan []DriftEntry, stacks.Len())
+	errCh := make(chan error, stacks.Len())
+	wg.Add(stacks.Len())
+	for _, s := range stacks.All {
+		go r.getEntries(ctx, wg, results, errCh, s)
+	}
+
+	wg.Wait()
+	close(results)
+	close(errCh)
+
+	exitCode := subcommands.ExitSuccess
+	for err := range errCh {
+		log.Printf("Error: %v", err)
+		exitCode = subcommands.ExitFailure
+	}
+
+	entries := []DriftEntry{}
+	for result := range results {
+		entries = append(entries, result...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].OurName != entries[j].OurName {
+			return entries[i].OurName < entries[j].OurName
+		}
+		return entries[i].Resource < entries[j].Resource
+	})
+
+	if err := output.New(r.General.Format).Print(os.Stdout, entries); err != nil {
+		return helpers.ExitErr(err)
+	}
+
+	return exitCode
+}
+
+func (r *Drift) getEntries(ctx context.Context, wg *sync.WaitGroup, results chan<- []DriftEntry, errCh chan<- error, s *config.StackConfig) {
+	defer wg.Done()
+
+	if r.Detect {
+		detectCtx, cancel := context.WithTimeout(ctx, r.DriftTimeout)
+		defer cancel()
+
+		if _, err := s.DetectDrift(detectCtx); err != nil {
+			errCh <- err
+			return
+		}
+	}
+
+	region, _ := s.Region()
+	awshelpers.Ratelimit(ctx, region, func() {
+		drifts, err := r.General.ResourceDrifts(ctx, s)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		entries := []DriftEntry{}
+		for _, drift := range drifts {
+			entries = append(entries, createEntries(region, s, drift)...)
+		}
+
+		results <- entries
+	})
+}
+
+// createEntries creates a row for each property of the resource which has
+// drifted. A deleted resource gets a single row.
+func createEntries(region string, s *config.StackConfig, drift *cloudformation.StackResourceDrift) []DriftEntry {
+	base := DriftEntry{
+		Region:   region,
+		OurName:  s.Name,
+		Resource: aws.StringValue(drift.LogicalResourceId),
+		Type:     aws.StringValue(drift.ResourceType),
+		Status:   aws.StringValue(drift.StackResourceDriftStatus),
+	}
+
+	if base.Status == cloudformation.StackResourceDriftStatusDeleted {
+		return []DriftEntry{base}
+	}
+
+	entries := []DriftEntry{}
+	for _, diff := range drift.PropertyDifferences {
+		entry := base
+		entry.Path = aws.StringValue(diff.PropertyPath)
+		entry.Expected = aws.StringValue(diff.ExpectedValue)
+		entry.Actual = aws.StringValue(diff.ActualValue)
+		entry.Difference = aws.StringValue(diff.DifferenceType)
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+type DriftEntry struct {
+	Region     string `header:"aws region" json:"region"`
+	OurName    string `header:"internal name" json:"name"`
+	Resource   string `header:"resource" json:"logical_id"`
+	Type       string `header:"type" json:"type"`
+	Status     string `header:"status" json:"status"`
+	Path       string `header:"property" json:"property_path"`
+	Expected   string `header:"expected" json:"expected"`
+	Actual     string `header:"actual" json:"actual"`
+	Difference string `header:"difference" json:"difference_type"`
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"sync"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/google/subcommands"
 	"github.com/hashicorp/go-multierror"
@@ -22,32 +23,48 @@ type FetchStacks struct {
 	General  *config.GeneralConfig
 	StacksDB *config.StacksDB
 
-	Noop bool
+	Noop           bool
+	IncludeDeleted bool
+	Prune          bool
 }
 
 func (*FetchStacks) Name() string     { return "fetch" }
 func (*FetchStacks) Synopsis() string { return "Fetch the stacks and their parameters" }
 func (*FetchStacks) Usage() string {
-	return `fetch:
-	Fetches the stacks and their parameters
+	return `fetch [-include-deleted] [-prune] [<filter1>, <filter2>...]
+	Fetches the stacks and their parameters. Deleted stacks are skipped
+	unless -include-deleted is given.
+
+	With -prune the local configs of stacks which have been deleted are moved
+	into the archive directory in the cache.
 `
 }
 
 func (r *FetchStacks) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&r.Noop, "noop", false, "noop don't write changes")
+	f.BoolVar(&r.IncludeDeleted, "include-deleted", false, "also fetch stacks which have been deleted")
+	f.BoolVar(&r.Prune, "prune", false, "archive the local configs of deleted stacks")
 }
 
 func (r *FetchStacks) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
 	fetchedStacks := config.StacksDB{}
+	// live is the ARNs of the stacks which haven't been deleted
+	live := map[string]bool{}
 
 	for _, reg := range r.General.Regions {
-		regStacks, err := r.getRegion(reg)
+		summaries, err := r.getRegion(ctx, reg)
 		if err != nil {
 			log.Printf("Error: %v", err)
 			return subcommands.ExitFailure
 		}
 
-		fetchedStacks.AddStack(regStacks...)
+		for _, summary := range summaries {
+			if aws.StringValue(summary.StackStatus) != cloudformation.StackStatusDeleteComplete {
+				live[aws.StringValue(summary.StackId)] = true
+			}
+		}
+
+		fetchedStacks.AddStack(r.convertToLocal(summaries)...)
 	}
 
 	filteredDiskStacks, err := r.StacksDB.Filter(f.Args()...)
@@ -91,9 +108,49 @@ func (r *FetchStacks) Execute(ctx context.Context, f *flag.FlagSet, _ ...interfa
 		return subcommands.ExitFailure
 	}
 
+	if r.Prune {
+		if err := r.pruneStacks(filteredDiskStacks, live); err != nil {
+			log.Printf("Error: %v", err)
+			return subcommands.ExitFailure
+		}
+	}
+
 	return exitCode
 }
 
+// pruneStacks archives the local configs of the stacks which are no longer
+// live. Stacks in regions which weren't fetched are left alone, as there is
+// no telling whether they still exist.
+func (r *FetchStacks) pruneStacks(stacks *config.StacksDB, live map[string]bool) error {
+	result := &multierror.Error{}
+
+	regions := map[string]bool{}
+	for _, reg := range r.General.Regions {
+		regions[reg] = true
+	}
+
+	for _, s := range stacks.All {
+		region, err := s.Region()
+		if err != nil || !regions[region] || live[s.ARN] {
+			continue
+		}
+
+		if r.Noop {
+			log.Printf("INFO: would archive %q, %s has been deleted", s.Source, s.Name)
+			continue
+		}
+
+		location, err := r.General.ArchiveStack(s)
+		if err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		log.Printf("INFO: archived %q to %q, %s has been deleted", s.Source, location, s.Name)
+	}
+
+	return result.ErrorOrNil()
+}
+
 func (r *FetchStacks) updateStacks(stacks []*config.StackConfig) error {
 	log.Printf("INFO: updating %d stacks", len(stacks))
 
@@ -142,26 +199,17 @@ func (r *FetchStacks) createStacks(stacks []*config.StackConfig) error {
 	return result.ErrorOrNil()
 }
 
-func (r *FetchStacks) getRegion(region string) ([]*config.StackConfig, error) {
+// getRegion lists the stacks in the region, leaving out those which have been
+// deleted unless r.IncludeDeleted is set.
+func (r *FetchStacks) getRegion(ctx context.Context, region string) ([]*cloudformation.StackSummary, error) {
 	log.Printf("INFO: Fetching %q", region) // TODO: switch to proper logger
 
-	client := awshelpers.GetCloudFormationClient(region)
-
-	stacks := []*config.StackConfig{}
-
-	input := &cloudformation.ListStacksInput{}
-	if err := client.ListStacksPagesWithContext(
-		context.TODO(),
-		input,
-		func(res *cloudformation.ListStacksOutput, more bool) bool {
-			stacks = append(stacks, r.convertToLocal(res.StackSummaries)...)
-
-			return more
-		}); err != nil {
-		return nil, err
+	var statuses []string
+	if !r.IncludeDeleted {
+		statuses = config.LiveStackStatuses()
 	}
 
-	return stacks, nil
+	return r.General.ListStacks(ctx, region, statuses)
 }
 
 func hydrateStacks(stacks []*config.StackConfig) error {
@@ -0,0 +1,74 @@
+package history
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/google/subcommands"
+	"github.com/keyneston/cftool/config"
+	"github.com/keyneston/cftool/helpers"
+	"github.com/keyneston/cftool/output"
+)
+
+type History struct {
+	General  *config.GeneralConfig
+	StacksDB *config.StacksDB
+}
+
+func (*History) Name() string { return "history" }
+func (*History) Synopsis() string {
+	return "List the versions of the live template cftool has seen"
+}
+
+func (*History) Usage() string {
+	return `history <stack> [<filter2>...]
+	Lists the versions of the live template, and its parameters, stored each
+	time cftool fetched them. Use the hash or timestamp with
+	"diff-template -against" to diff against that version.
+`
+}
+
+func (r *History) SetFlags(f *flag.FlagSet) {
+}
+
+func (r *History) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() == 0 {
+		return helpers.Exitf("history requires a stack")
+	}
+
+	stacks, err := r.StacksDB.Filter(f.Args()...)
+	if err != nil {
+		return helpers.ExitErr(err)
+	}
+
+	entries := []HistoryEntry{}
+	for _, s := range stacks.All {
+		history, err := s.History()
+		if err != nil {
+			return helpers.ExitErr(err)
+		}
+
+		for _, h := range history {
+			entries = append(entries, HistoryEntry{
+				OurName:   s.Name,
+				Timestamp: h.Timestamp(),
+				Hash:      h.Hash,
+				Params:    len(h.Params),
+			})
+		}
+	}
+
+	if err := output.New(r.General.Format).Print(os.Stdout, entries); err != nil {
+		return helpers.ExitErr(err)
+	}
+
+	return subcommands.ExitSuccess
+}
+
+type HistoryEntry struct {
+	OurName   string `header:"internal name" json:"name"`
+	Timestamp string `header:"timestamp" json:"timestamp"`
+	Hash      string `header:"hash" json:"hash"`
+	Params    int    `header:"params" json:"params"`
+}
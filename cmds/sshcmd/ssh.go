@@ -88,7 +88,7 @@ func (r *SSHcmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{})
 	}
 	offset := r.ServerOffset % uint(len(allServers))
 
-	r.General.Log.Debug("Picking server %d", offset)
+	r.General.Log.Debugf("Picking server %d", offset)
 	server := allServers[offset]
 
 	if r.Pdsh {
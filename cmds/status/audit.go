@@ -0,0 +1,212 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/google/subcommands"
+	"github.com/keyneston/cftool/awshelpers"
+	"github.com/keyneston/cftool/config"
+	"github.com/keyneston/cftool/helpers"
+	"github.com/keyneston/cftool/output"
+)
+
+// Problems the audit finds.
+const (
+	// ProblemUnmanaged is a live stack with no local config.
+	ProblemUnmanaged = "unmanaged"
+	// ProblemDeleted is a local config whose stack has been deleted.
+	ProblemDeleted = "deleted"
+	// ProblemNotFound is a local config whose stack can't be found at all.
+	ProblemNotFound = "not found"
+)
+
+// audit lists the live stacks in the configured regions which have no local
+// config, and the local configs whose stacks no longer exist.
+func (r *StatusStacks) audit(ctx context.Context, stacks *config.StacksDB, filters []string) subcommands.ExitStatus {
+	entries := []AuditEntry{}
+	errors := []error{}
+
+	unmanaged, err := r.findUnmanaged(ctx, filters)
+	if err != nil {
+		errors = append(errors, err)
+	}
+	entries = append(entries, unmanaged...)
+
+	wg := &sync.WaitGroup{}
+	results := make(chan AuditEntry, stacks.Len())
+	errCh := make(chan error, stacks.Len())
+	wg.Add(stacks.Len())
+	for _, s := range stacks.All {
+		go r.checkOrphaned(ctx, wg, results, errCh, s)
+	}
+
+	wg.Wait()
+	close(results)
+	close(errCh)
+
+	for entry := range results {
+		entries = append(entries, entry)
+	}
+	for err := range errCh {
+		errors = append(errors, err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Problem != entries[j].Problem {
+			return entries[i].Problem < entries[j].Problem
+		}
+		if entries[i].Stack != entries[j].Stack {
+			return entries[i].Stack < entries[j].Stack
+		}
+		return entries[i].Region < entries[j].Region
+	})
+
+	for _, err := range errors {
+		r.General.Log.Errorf("%v", err)
+	}
+
+	if err := output.New(r.General.Format).Print(os.Stdout, entries); err != nil {
+		return helpers.ExitErr(err)
+	}
+
+	if len(errors) != 0 {
+		return subcommands.ExitFailure
+	}
+
+	return subcommands.ExitSuccess
+}
+
+// findUnmanaged lists the stacks in each of the configured regions which
+// aren't in the StacksDB. Nested stacks are managed through their parent, so
+// they are left out.
+//
+// The stacks are keyed by ARN rather than added to a StacksDB, as stacks in
+// different regions may share a name.
+func (r *StatusStacks) findUnmanaged(ctx context.Context, filters []string) ([]AuditEntry, error) {
+	matchers := []*regexp.Regexp{}
+	for _, filter := range filters {
+		re, err := regexp.Compile(filter)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, re)
+	}
+
+	entries := []AuditEntry{}
+	seen := map[string]bool{}
+	for _, region := range r.General.Regions {
+		summaries, err := r.General.ListStacks(ctx, region, config.LiveStackStatuses())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, summary := range summaries {
+			arn := aws.StringValue(summary.StackId)
+			name := aws.StringValue(summary.StackName)
+
+			if summary.ParentId != nil || seen[arn] || r.StacksDB.FindByARN(arn) != nil {
+				continue
+			}
+			if !matchesAny(matchers, name, arn) {
+				continue
+			}
+			seen[arn] = true
+
+			entries = append(entries, AuditEntry{
+				Region:      region,
+				Stack:       name,
+				StackStatus: aws.StringValue(summary.StackStatus),
+				Problem:     ProblemUnmanaged,
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+// matchesAny reports whether any of the matchers match the name or ARN, the
+// same as StacksDB.Filter. With no matchers everything matches.
+func matchesAny(matchers []*regexp.Regexp, name, arn string) bool {
+	if len(matchers) == 0 {
+		return true
+	}
+
+	for _, re := range matchers {
+		if re.MatchString(name) || re.MatchString(arn) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkOrphaned sends an entry if the stack no longer exists. The stack is
+// looked up by ARN, as a deleted stack can only be described that way.
+func (r *StatusStacks) checkOrphaned(ctx context.Context, wg *sync.WaitGroup, results chan<- AuditEntry, errors chan<- error, s *config.StackConfig) {
+	defer wg.Done()
+
+	region, err := s.Region()
+	if err != nil {
+		errors <- fmt.Errorf("%s: %v", s.Name, err)
+		return
+	}
+
+	awshelpers.Ratelimit(ctx, region, func() {
+		client, err := s.GetClient()
+		if err != nil {
+			errors <- err
+			return
+		}
+
+		entry := AuditEntry{
+			Region:  region,
+			Stack:   s.StackName(),
+			OurName: s.Name,
+			Source:  s.Source,
+		}
+
+		live, err := client.DescribeStacksWithContext(ctx, &cloudformation.DescribeStacksInput{
+			StackName: aws.String(s.ARN),
+		})
+		if isNotFound(err) || (err == nil && len(live.Stacks) == 0) {
+			entry.Problem = ProblemNotFound
+			results <- entry
+			return
+		} else if err != nil {
+			errors <- fmt.Errorf("DescribeStacks %s: %v", s.Name, err)
+			return
+		}
+
+		entry.StackStatus = aws.StringValue(live.Stacks[0].StackStatus)
+		if entry.StackStatus == cloudformation.StackStatusDeleteComplete {
+			entry.Problem = ProblemDeleted
+			results <- entry
+		}
+	})
+}
+
+// isNotFound reports whether err is CloudFormation saying the stack doesn't
+// exist, which it reports as a ValidationError.
+func isNotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == "ValidationError" && strings.Contains(aerr.Message(), "does not exist")
+}
+
+type AuditEntry struct {
+	Region      string `header:"aws region" json:"region"`
+	Stack       string `header:"stackname" json:"stack_name"`
+	OurName     string `header:"internal name" json:"name"`
+	StackStatus string `header:"stack status" json:"stack_status"`
+	Problem     string `header:"problem" json:"problem"`
+	// Source is the local config of the stack, if it has one.
+	Source string `header:"source" json:"source"`
+}
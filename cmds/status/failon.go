@@ -0,0 +1,104 @@
+package status
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// Conditions -fail-on can be given.
+const (
+	FailTemplateDrift = "template-drift"
+	FailCfnDrift      = "cfn-drift"
+	FailParamDrift    = "param-drift"
+	FailUnstable      = "unstable"
+)
+
+// FailConditions lists every condition, in the order problems are reported.
+var FailConditions = []string{FailTemplateDrift, FailCfnDrift, FailParamDrift, FailUnstable}
+
+// parseFailOn splits the comma separated conditions, checking each is one of
+// FailConditions.
+func parseFailOn(value string) ([]string, error) {
+	conditions := []string{}
+	for _, c := range strings.Split(value, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+
+		if !contains(FailConditions, c) {
+			return nil, fmt.Errorf("invalid -fail-on %q, must be one of %s", c, strings.Join(FailConditions, ", "))
+		}
+		conditions = append(conditions, c)
+	}
+
+	return conditions, nil
+}
+
+// problems returns a description of each of the conditions the entry meets.
+func problems(entry StatusEntry, conditions []string) []string {
+	found := []string{}
+
+	for _, c := range FailConditions {
+		if !contains(conditions, c) {
+			continue
+		}
+
+		switch c {
+		case FailTemplateDrift:
+			if entry.TemplateDiff != nil && *entry.TemplateDiff {
+				found = append(found, "template drift")
+			}
+		case FailCfnDrift:
+			if entry.CloudFormationDrift == cloudformation.StackDriftStatusDrifted && entry.unignoredDrift {
+				found = append(found, "cloudformation drift")
+			}
+		case FailParamDrift:
+			if entry.ParamDrift != "" && entry.ParamDrift != "No" {
+				found = append(found, "param drift: "+entry.ParamDrift)
+			}
+		case FailUnstable:
+			if isUnstable(entry.StackStatus) {
+				found = append(found, "unstable: "+entry.StackStatus)
+			}
+		}
+	}
+
+	return found
+}
+
+// writeProblems writes one line per problem found with the entries, and
+// reports whether there were any.
+func writeProblems(w io.Writer, entries []StatusEntry, conditions []string) bool {
+	failed := false
+
+	for _, entry := range entries {
+		for _, problem := range problems(entry, conditions) {
+			fmt.Fprintf(w, "%s: %s\n", entry.OurName, problem)
+			failed = true
+		}
+	}
+
+	return failed
+}
+
+// isUnstable reports whether the stack is in progress, failed, or was rolled
+// back.
+func isUnstable(status string) bool {
+	return isInProgress(status) ||
+		strings.HasSuffix(status, "_FAILED") ||
+		strings.HasSuffix(status, "ROLLBACK_COMPLETE")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,145 @@
+package status
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func TestParseFailOn(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{}, false},
+		{"unstable", []string{FailUnstable}, false},
+		{"template-drift, param-drift,", []string{FailTemplateDrift, FailParamDrift}, false},
+		{"cfn-drift,drift", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseFailOn(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFailOn(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFailOn(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProblems(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name       string
+		entry      StatusEntry
+		conditions []string
+		want       []string
+	}{
+		{
+			name:       "healthy",
+			entry:      StatusEntry{StackStatus: "UPDATE_COMPLETE", TemplateDiff: &no, ParamDrift: "No", CloudFormationDrift: cloudformation.StackDriftStatusInSync},
+			conditions: FailConditions,
+			want:       []string{},
+		},
+		{
+			name:       "template drift",
+			entry:      StatusEntry{TemplateDiff: &yes},
+			conditions: []string{FailTemplateDrift},
+			want:       []string{"template drift"},
+		},
+		{
+			name:       "template unknown",
+			entry:      StatusEntry{},
+			conditions: []string{FailTemplateDrift},
+			want:       []string{},
+		},
+		{
+			name:       "cfn drift",
+			entry:      StatusEntry{CloudFormationDrift: cloudformation.StackDriftStatusDrifted, unignoredDrift: true},
+			conditions: []string{FailCfnDrift},
+			want:       []string{"cloudformation drift"},
+		},
+		{
+			name:       "cfn drift all ignored",
+			entry:      StatusEntry{CloudFormationDrift: cloudformation.StackDriftStatusDrifted},
+			conditions: []string{FailCfnDrift},
+			want:       []string{},
+		},
+		{
+			name:       "param drift",
+			entry:      StatusEntry{ParamDrift: "Size, Count"},
+			conditions: []string{FailParamDrift},
+			want:       []string{"param drift: Size, Count"},
+		},
+		{
+			name:       "unstable",
+			entry:      StatusEntry{StackStatus: "UPDATE_ROLLBACK_COMPLETE"},
+			conditions: []string{FailUnstable},
+			want:       []string{"unstable: UPDATE_ROLLBACK_COMPLETE"},
+		},
+		{
+			name:       "condition not asked for",
+			entry:      StatusEntry{StackStatus: "UPDATE_IN_PROGRESS", TemplateDiff: &yes},
+			conditions: []string{FailParamDrift},
+			want:       []string{},
+		},
+		{
+			name:       "in FailConditions order",
+			entry:      StatusEntry{StackStatus: "CREATE_FAILED", TemplateDiff: &yes},
+			conditions: []string{FailUnstable, FailTemplateDrift},
+			want:       []string{"template drift", "unstable: CREATE_FAILED"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := problems(tt.entry, tt.conditions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("problems() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsUnstable(t *testing.T) {
+	for status, want := range map[string]bool{
+		"CREATE_COMPLETE":                     false,
+		"UPDATE_COMPLETE":                     false,
+		"IMPORT_COMPLETE":                     false,
+		"UPDATE_IN_PROGRESS":                  true,
+		"UPDATE_COMPLETE_CLEANUP_IN_PROGRESS": true,
+		"CREATE_FAILED":                       true,
+		"ROLLBACK_COMPLETE":                   true,
+		"UPDATE_ROLLBACK_COMPLETE":            true,
+	} {
+		if got := isUnstable(status); got != want {
+			t.Errorf("isUnstable(%q) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestWriteProblems(t *testing.T) {
+	yes := true
+	entries := []StatusEntry{
+		{OurName: "a", StackStatus: "UPDATE_COMPLETE"},
+		{OurName: "b", StackStatus: "UPDATE_FAILED", TemplateDiff: &yes},
+	}
+
+	buf := &bytes.Buffer{}
+	if !writeProblems(buf, entries, FailConditions) {
+		t.Error("writeProblems() = false, want true")
+	}
+	if want := "b: template drift\nb: unstable: UPDATE_FAILED\n"; buf.String() != want {
+		t.Errorf("writeProblems() wrote %q, want %q", buf.String(), want)
+	}
+
+	if writeProblems(&bytes.Buffer{}, entries[:1], FailConditions) {
+		t.Error("writeProblems() for a healthy stack = true, want false")
+	}
+}
@@ -5,51 +5,135 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/google/subcommands"
 	"github.com/keyneston/cftool/awshelpers"
 	"github.com/keyneston/cftool/config"
-	"github.com/lensesio/tableprinter"
+	"github.com/keyneston/cftool/helpers"
+	"github.com/keyneston/cftool/output"
 )
 
 type StatusStacks struct {
 	General  *config.GeneralConfig
 	StacksDB *config.StacksDB
+
+	DetectDrift  bool
+	DriftTimeout time.Duration
+	Columns      string
+
+	Watch       bool
+	Interval    time.Duration
+	UntilStable bool
+
+	Audit bool
+
+	FailOn string
+	failOn []string
 }
 
 func (*StatusStacks) Name() string     { return "status" }
 func (*StatusStacks) Synopsis() string { return "Lists the stacks and their status" }
 func (*StatusStacks) Usage() string {
-	return `status [<filter1>, <filter2>...]
+	return `status [-detect-drift] [-columns <col1>,<col2>...] [-watch [-interval 10s] [-until-stable]] [-fail-on <cond1>,<cond2>...] [<filter1>, <filter2>...]
+status -audit [<filter1>, <filter2>...]
 	Lists the stacks and their status. Filters are additive.
+
+	With -detect-drift CloudFormation drift detection is run on each stack
+	first, rather than showing the result of the last detection.
+
+	-columns picks which columns to show, named by their header with dashes
+	for spaces, e.g. "internal-name,stack-status,param-drift", or by their
+	key in the -format json output.
+
+	With -watch the status is refreshed every -interval, highlighting stacks
+	which are in progress or failed. With -until-stable it exits once no
+	stack is in progress.
+
+	-fail-on exits non-zero if any stack meets one of the conditions:
+	template-drift, cfn-drift, param-drift, or unstable (in progress, failed,
+	or rolled back). Drift matching a drift_ignore rule doesn't count as
+	cfn-drift. Each problem is written to stderr on its own line.
+
+	With -audit the stacks in the configured regions which have no local
+	config are listed instead, along with the local configs whose stacks
+	have been deleted or can't be found.
 `
 }
 
 func (r *StatusStacks) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&r.DetectDrift, "detect-drift", false, "run drift detection on each stack")
+	f.DurationVar(&r.DriftTimeout, "drift-timeout", 5*time.Minute, "how long to wait for drift detection")
+	f.StringVar(&r.Columns, "columns", "", "comma separated columns to show; defaults to all")
+	f.BoolVar(&r.Watch, "watch", false, "keep refreshing the status")
+	f.DurationVar(&r.Interval, "interval", 10*time.Second, "with -watch, how often to refresh")
+	f.BoolVar(&r.UntilStable, "until-stable", false, "with -watch, exit once no stack is in progress")
+	f.BoolVar(&r.Audit, "audit", false, "list unmanaged stacks and local configs for deleted stacks")
+	f.StringVar(&r.FailOn, "fail-on", "", "comma separated conditions to exit non-zero on: "+strings.Join(FailConditions, ", "))
 }
 
 func (r *StatusStacks) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
 	r.General.Log.Debug("Starting StatusStacks.Execute()")
 
-	entries := []StatusEntry{}
-	errors := []error{}
+	printer := output.New(r.General.Format, r.columns()...)
+	// Check the columns before making any calls to AWS
+	if err := printer.Check([]StatusEntry{}); err != nil {
+		return helpers.ExitErr(err)
+	}
 
-	wg := &sync.WaitGroup{}
+	failOn, err := parseFailOn(r.FailOn)
+	if err != nil {
+		return helpers.ExitErr(err)
+	}
+	r.failOn = failOn
 
 	stacks, err := r.StacksDB.Filter(f.Args()...)
 	if err != nil {
 		r.General.Log.Errorf("%v", err)
 		return subcommands.ExitFailure
 	}
-	r.General.Log.Debug("debug: got statcks %#v", stacks)
+	r.General.Log.Debugf("debug: got statcks %#v", stacks)
+
+	if r.Audit {
+		return r.audit(ctx, stacks, f.Args())
+	}
+
+	if r.Watch {
+		return r.watch(ctx, printer, stacks)
+	}
 
-	results := make(chan StatusEntry, r.StacksDB.Len())
-	errCh := make(chan error, r.StacksDB.Len())
+	entries, errors := r.getEntries(ctx, stacks)
+	for _, err := range errors {
+		r.General.Log.Errorf("%v", err)
+	}
+
+	if err := printer.Print(os.Stdout, entries); err != nil {
+		return helpers.ExitErr(err)
+	}
+
+	if writeProblems(os.Stderr, entries, r.failOn) || len(errors) != 0 {
+		return subcommands.ExitFailure
+	}
+
+	return subcommands.ExitSuccess
+}
+
+// getEntries fetches the status of every stack, sorted by internal name.
+func (r *StatusStacks) getEntries(ctx context.Context, stacks *config.StacksDB) ([]StatusEntry, []error) {
+	entries := []StatusEntry{}
+	errors := []error{}
+
+	wg := &sync.WaitGroup{}
+	results := make(chan StatusEntry, stacks.Len())
+	errCh := make(chan error, stacks.Len())
 	wg.Add(stacks.Len())
 	for _, s := range stacks.All {
-		go r.getEntry(wg, results, errCh, s)
+		go r.getEntry(ctx, wg, results, errCh, s)
 	}
 
 	wg.Wait()
@@ -59,26 +143,46 @@ func (r *StatusStacks) Execute(ctx context.Context, f *flag.FlagSet, _ ...interf
 	for entry := range results {
 		entries = append(entries, entry)
 	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].OurName < entries[j].OurName
+	})
 
 	for err := range errCh {
-		r.General.Log.Errorf("%v", err)
 		errors = append(errors, err)
 	}
 
-	tableprinter.Print(os.Stdout, entries)
+	return entries, errors
+}
 
-	if len(errors) != 0 {
-		return subcommands.ExitFailure
+func (r *StatusStacks) columns() []string {
+	columns := []string{}
+	for _, c := range strings.Split(r.Columns, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			columns = append(columns, c)
+		}
 	}
 
-	return subcommands.ExitSuccess
+	return columns
 }
 
-func (r *StatusStacks) getEntry(wg *sync.WaitGroup, results chan<- StatusEntry, errors chan<- error, s *config.StackConfig) {
+func (r *StatusStacks) getEntry(ctx context.Context, wg *sync.WaitGroup, results chan<- StatusEntry, errors chan<- error, s *config.StackConfig) {
 	defer wg.Done()
 
+	var drift *cloudformation.DescribeStackDriftDetectionStatusOutput
+	if r.DetectDrift {
+		detectCtx, cancel := context.WithTimeout(ctx, r.DriftTimeout)
+		defer cancel()
+
+		var err error
+		drift, err = s.DetectDrift(detectCtx)
+		if err != nil {
+			errors <- err
+			return
+		}
+	}
+
 	region, _ := s.Region()
-	awshelpers.Ratelimit(context.TODO(), region, func() {
+	awshelpers.Ratelimit(ctx, region, func() {
 		live, err := s.GetLive()
 		if err != nil {
 			r.General.Log.Errorf("%v", err)
@@ -94,19 +198,47 @@ func (r *StatusStacks) getEntry(wg *sync.WaitGroup, results chan<- StatusEntry,
 		cur := live.Stacks[0]
 		region, _ := s.Region()
 
+		updated := cur.LastUpdatedTime
+		if updated == nil {
+			updated = cur.CreationTime
+		}
+		// The closest the stack gives to when it entered its current status
+		since := updated
+		if cur.DeletionTime != nil {
+			since = cur.DeletionTime
+		}
+
 		entry := StatusEntry{
-			Region:              region,
-			OurName:             s.Name,
-			Name:                *cur.StackName,
-			CloudFormationDrift: "unknown",
+			Region:                region,
+			OurName:               s.Name,
+			Name:                  *cur.StackName,
+			StackStatus:           aws.StringValue(cur.StackStatus),
+			LastUpdated:           formatTime(updated),
+			TimeInState:           formatDuration(time.Since(aws.TimeValue(since))),
+			since:                 aws.TimeValue(since),
+			TerminationProtection: aws.BoolValue(cur.EnableTerminationProtection),
+			CloudFormationDrift:   "unknown",
+		}
+
+		// Without parameters on disk there is nothing to compare against
+		if s.Params != nil {
+			entry.ParamDrift = "No"
+			if changed := s.ChangedParamsFrom(config.StackParams(cur)); len(changed) > 0 {
+				entry.ParamDrift = strings.Join(changed, ", ")
+			}
 		}
 
 		if s.File != "" {
-			liveTemplateHash, err := s.GetLiveTemplateHash()
+			liveTemplate, err := s.GetLiveTemplate()
 			if err != nil {
 				errors <- err
 				return
 			}
+			if err := s.RecordHistory(liveTemplate, config.StackParams(cur)); err != nil {
+				r.General.Log.Warningf("%s: unable to record template history: %v", s.Name, err)
+			}
+			liveTemplateHash := s.TemplateHash(liveTemplate)
+
 			diskTemplateHash, err := s.GetDiskTemplateHash()
 			if err != nil {
 				errors <- err
@@ -118,6 +250,26 @@ func (r *StatusStacks) getEntry(wg *sync.WaitGroup, results chan<- StatusEntry,
 
 		if cur.DriftInformation != nil && cur.DriftInformation.StackDriftStatus != nil {
 			entry.CloudFormationDrift = *cur.DriftInformation.StackDriftStatus
+			entry.DriftCheckedAt = formatTime(cur.DriftInformation.LastCheckTimestamp)
+		}
+
+		if drift != nil {
+			entry.CloudFormationDrift = aws.StringValue(drift.StackDriftStatus)
+			if aws.StringValue(drift.DetectionStatus) == cloudformation.StackDriftDetectionStatusDetectionFailed {
+				entry.CloudFormationDrift = cloudformation.StackDriftDetectionStatusDetectionFailed
+			}
+			entry.DriftCheckedAt = formatTime(drift.Timestamp)
+		}
+
+		// The stack level status doesn't take drift_ignore into account, so
+		// check which resources drifted before failing on it
+		if contains(r.failOn, FailCfnDrift) && entry.CloudFormationDrift == cloudformation.StackDriftStatusDrifted {
+			drifts, err := r.General.ResourceDrifts(ctx, s)
+			if err != nil {
+				errors <- err
+				return
+			}
+			entry.unignoredDrift = len(drifts) > 0
 		}
 
 		results <- entry
@@ -125,9 +277,38 @@ func (r *StatusStacks) getEntry(wg *sync.WaitGroup, results chan<- StatusEntry,
 }
 
 type StatusEntry struct {
-	Region              string `header:"aws region"`
-	Name                string `header:"stackname"`
-	OurName             string `header:"internal name"`
-	CloudFormationDrift string `header:"cloudformation drift"`
-	TemplateDiff        *bool  `header:"template drift"`
+	Region                string `header:"aws region" json:"region"`
+	Name                  string `header:"stackname" json:"stack_name"`
+	OurName               string `header:"internal name" json:"name"`
+	StackStatus           string `header:"stack status" json:"stack_status"`
+	LastUpdated           string `header:"last updated" json:"last_updated"`
+	TimeInState           string `header:"time in state" json:"time_in_state"`
+	TerminationProtection bool   `header:"termination protection" json:"termination_protection"`
+	CloudFormationDrift   string `header:"cloudformation drift" json:"cloudformation_drift"`
+	DriftCheckedAt        string `header:"drift checked" json:"drift_checked"`
+	TemplateDiff          *bool  `header:"template drift" json:"template_drift"`
+	// ParamDrift lists the parameters which differ from the live stack, or
+	// is "No" if none do.
+	ParamDrift string `header:"param drift" json:"param_drift"`
+
+	since time.Time
+	// unignoredDrift is set if any of the drift isn't covered by the
+	// drift_ignore rules. It is only checked for -fail-on cfn-drift.
+	unignoredDrift bool
+}
+
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		return ""
+	}
+
+	return d.Round(time.Second).String()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Local().Format(time.RFC3339)
 }
@@ -0,0 +1,118 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/google/subcommands"
+	"github.com/keyneston/cftool/config"
+	"github.com/keyneston/cftool/helpers"
+	"github.com/keyneston/cftool/output"
+)
+
+// clearScreen moves the cursor to the top left and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+var (
+	inProgress = color.New(color.FgYellow).SprintFunc()
+	failed     = color.New(color.FgRed).SprintFunc()
+)
+
+// stackState is the status a stack was last seen in, and since when.
+type stackState struct {
+	status string
+	since  time.Time
+}
+
+// watch refreshes the status every r.Interval until the context is done or,
+// with r.UntilStable, until no stack is in progress.
+func (r *StatusStacks) watch(ctx context.Context, printer *output.Printer, stacks *config.StacksDB) subcommands.ExitStatus {
+	if r.Interval <= 0 {
+		return helpers.Exitf("Invalid interval set: %v", r.Interval)
+	}
+
+	states := map[string]stackState{}
+	for {
+		entries, errors := r.getEntries(ctx, stacks)
+		// Drift detection is slow, and doesn't need repeating every refresh
+		r.DetectDrift = false
+
+		stable := len(errors) == 0
+		for i, entry := range entries {
+			entries[i].TimeInState = formatDuration(time.Since(trackState(states, entry)))
+
+			if isInProgress(entry.StackStatus) {
+				stable = false
+			}
+		}
+
+		// Check for problems before highlighting, which changes the statuses
+		done := r.UntilStable && stable
+		summary := &bytes.Buffer{}
+		problemsFound := done && writeProblems(summary, entries, r.failOn)
+
+		if printer.IsTable() {
+			fmt.Fprint(os.Stdout, clearScreen)
+			fmt.Fprintf(os.Stdout, "Every %v: status %s\n\n", r.Interval, time.Now().Format(time.RFC3339))
+			highlight(entries)
+		}
+		if err := printer.Print(os.Stdout, entries); err != nil {
+			return helpers.ExitErr(err)
+		}
+		for _, err := range errors {
+			r.General.Log.Errorf("%v", err)
+		}
+
+		if done {
+			if problemsFound {
+				io.Copy(os.Stderr, summary)
+				return subcommands.ExitFailure
+			}
+			return subcommands.ExitSuccess
+		}
+
+		select {
+		case <-ctx.Done():
+			return subcommands.ExitSuccess
+		case <-time.After(r.Interval):
+		}
+	}
+}
+
+// trackState returns when the stack entered its current status. The first
+// time a stack is seen this is the best guess the stack's times give, after
+// that it is when the change of status was seen.
+func trackState(states map[string]stackState, entry StatusEntry) time.Time {
+	state, ok := states[entry.OurName]
+	switch {
+	case !ok:
+		state = stackState{status: entry.StackStatus, since: entry.since}
+	case state.status != entry.StackStatus:
+		state = stackState{status: entry.StackStatus, since: time.Now()}
+	}
+	states[entry.OurName] = state
+
+	return state.since
+}
+
+// highlight colours the status of stacks which are in progress or failed.
+func highlight(entries []StatusEntry) {
+	for i, entry := range entries {
+		switch {
+		case isInProgress(entry.StackStatus):
+			entries[i].StackStatus = inProgress(entry.StackStatus)
+		case strings.HasSuffix(entry.StackStatus, "_FAILED"):
+			entries[i].StackStatus = failed(entry.StackStatus)
+		}
+	}
+}
+
+func isInProgress(status string) bool {
+	return strings.HasSuffix(status, "_IN_PROGRESS")
+}
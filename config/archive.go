@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveDir is where the configs of deleted stacks are moved to by
+// `fetch -prune`. It is skipped when loading the stacks.
+func (g GeneralConfig) ArchiveDir() string {
+	return filepath.Join(g.CacheDir, "archive")
+}
+
+// ArchiveStack moves the stack's config into the ArchiveDir, keeping its path
+// relative to the CacheDir, and returns where it was moved to.
+func (g GeneralConfig) ArchiveStack(s *StackConfig) (string, error) {
+	if s.Source == "" {
+		return "", fmt.Errorf("%s has no config file to archive", s.Name)
+	}
+
+	relative, err := filepath.Rel(g.CacheDir, s.Source)
+	// Configs from outside the CacheDir are archived by name
+	if err != nil || strings.HasPrefix(relative, "..") {
+		relative = filepath.Base(s.Source)
+	}
+	location := filepath.Join(g.ArchiveDir(), relative)
+
+	if _, err := os.Stat(location); err == nil {
+		return "", fmt.Errorf("archiving %q: %q already exists", s.Source, location)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(location), 0o700); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(s.Source, location); err != nil {
+		return "", fmt.Errorf("archiving %q: %v", s.Source, err)
+	}
+
+	return location, nil
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Changeset names must conform to `[a-zA-Z][-a-zA-Z0-9]*` and be at most
+// maxChangeSetName characters.
+const (
+	maxChangeSetName = 128
+	// changeSetHashes is the length of the hashes ChangeSetName appends to
+	// the prefix, including the separating dash.
+	changeSetHashes = 32 + 1 + 16
+)
+
+// invalidChangeSetChars matches anything not allowed in a changeset name.
+var invalidChangeSetChars = regexp.MustCompile(`[^-a-zA-Z0-9]`)
+
+// ChangeSetPrefix is the prefix of the names of all changesets cftool creates
+// for this stack. Names not starting with a letter are prefixed with
+// "cftool-", and long names are truncated so the full changeset name fits.
+func (s *StackConfig) ChangeSetPrefix() string {
+	prefix := invalidChangeSetChars.ReplaceAllString(s.Name, "-")
+	if prefix == "" || !isLetter(prefix[0]) {
+		prefix = "cftool-" + prefix
+	}
+
+	if max := maxChangeSetName - changeSetHashes - 1; len(prefix) > max {
+		prefix = prefix[:max]
+	}
+
+	return prefix + "-"
+}
+
+// ChangeSetName is the name of the changeset for the current disk template
+// and parameters. The hashes are shortened to keep within the 128 character
+// limit on changeset names.
+func (s *StackConfig) ChangeSetName() (string, error) {
+	diskHash, err := s.GetDiskTemplateHash()
+	if err != nil {
+		return "", err
+	}
+	paramsHash := HashParams(s.Params)
+
+	return fmt.Sprintf("%s%s-%s", s.ChangeSetPrefix(), diskHash[:32], paramsHash[:16]), nil
+}
+
+// ChangeSetPattern matches the names of the changesets cftool created for
+// this stack: those named by ChangeSetName, and those named by older versions
+// with the full 64 character template hash.
+func (s *StackConfig) ChangeSetPattern() *regexp.Regexp {
+	prefix := regexp.QuoteMeta(s.ChangeSetPrefix())
+	return regexp.MustCompile(`^` + prefix + `([0-9a-f]{32}-[0-9a-f]{16}|[0-9a-f]{64})$`)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestChangeSetPrefix(t *testing.T) {
+	valid := regexp.MustCompile(`^[a-zA-Z][-a-zA-Z0-9]*$`)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"us-east-c1", "us-east-c1-"},
+		{"us_east:c1", "us-east-c1-"},
+		{"1-stack", "cftool-1-stack-"},
+		{"_stack", "cftool--stack-"},
+		{strings.Repeat("a", 100), strings.Repeat("a", 78) + "-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := (&StackConfig{Name: tt.name}).ChangeSetPrefix()
+			if got != tt.want {
+				t.Errorf("ChangeSetPrefix() = %q, want %q", got, tt.want)
+			}
+
+			name := got + strings.Repeat("0", 32) + "-" + strings.Repeat("0", 16)
+			if !valid.MatchString(name) || len(name) > maxChangeSetName {
+				t.Errorf("changeset name %q is invalid", name)
+			}
+		})
+	}
+}
+
+func TestChangeSetPattern(t *testing.T) {
+	pattern := (&StackConfig{Name: "us-east-c1"}).ChangeSetPattern()
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"us-east-c1-" + strings.Repeat("a", 32) + "-" + strings.Repeat("0", 16), true},
+		{"us-east-c1-" + strings.Repeat("0123456789abcdef", 4), true},
+		{"us-east-c1-hotfix", false},
+		{"us-east-c1-" + strings.Repeat("a", 32) + "-" + strings.Repeat("0", 16) + "-manual", false},
+		{"us-east-c1-" + strings.Repeat("A", 32) + "-" + strings.Repeat("0", 16), false},
+		{"us-east-c10-" + strings.Repeat("a", 32) + "-" + strings.Repeat("0", 16), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pattern.MatchString(tt.name); got != tt.want {
+				t.Errorf("MatchString(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -8,6 +8,15 @@ const (
 	EnvVariable     = "CFTOOLRC"
 )
 
+// DefaultProtectedTypes are the resource types which hold state, and so are
+// protected from being removed or replaced unless explicitly allowed.
+var DefaultProtectedTypes = []string{
+	"AWS::RDS::DBInstance",
+	"AWS::RDS::DBCluster",
+	"AWS::DynamoDB::Table",
+	"AWS::EC2::Volume",
+}
+
 func FindConfig() string {
 	env := os.Getenv(EnvVariable)
 	if env != "" {
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	cf "github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/keyneston/cftool/awshelpers"
+)
+
+// driftPollInterval is how often the drift detection status is checked.
+const driftPollInterval = 5 * time.Second
+
+// DriftIgnoreRule describes drift which is known to be benign, such as the
+// DesiredCapacity of an autoscaling group, so it isn't reported. Empty fields
+// match anything.
+type DriftIgnoreRule struct {
+	ResourceType string `json:"resource_type" yaml:"resource_type"`
+	LogicalID    string `json:"logical_id" yaml:"logical_id"`
+	// PropertyPath matches the path of the property and anything beneath
+	// it, e.g. "/Tags" matches "/Tags/0/Value".
+	PropertyPath string `json:"property_path" yaml:"property_path"`
+}
+
+// Matches reports whether the rule covers the drifted property.
+func (r DriftIgnoreRule) Matches(resourceType, logicalID, propertyPath string) bool {
+	if r.ResourceType != "" && r.ResourceType != resourceType {
+		return false
+	}
+	if r.LogicalID != "" && r.LogicalID != logicalID {
+		return false
+	}
+	if r.PropertyPath == "" {
+		return true
+	}
+
+	return propertyPath == r.PropertyPath || strings.HasPrefix(propertyPath, strings.TrimSuffix(r.PropertyPath, "/")+"/")
+}
+
+// IgnoreDrift reports whether any of the drift_ignore rules cover the drifted
+// property.
+func (g GeneralConfig) IgnoreDrift(resourceType, logicalID, propertyPath string) bool {
+	for _, rule := range g.DriftIgnore {
+		if rule != nil && rule.Matches(resourceType, logicalID, propertyPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResourceDrifts returns the resources the last drift detection found to
+// have been modified or deleted, leaving out the drift covered by the
+// drift_ignore rules. Resources whose drift is all ignored are left out
+// entirely.
+func (g GeneralConfig) ResourceDrifts(ctx context.Context, s *StackConfig) ([]*cf.StackResourceDrift, error) {
+	client, err := s.GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	stackName := s.StackName()
+	drifts := []*cf.StackResourceDrift{}
+	if err := client.DescribeStackResourceDriftsPagesWithContext(ctx,
+		&cf.DescribeStackResourceDriftsInput{
+			StackName: &stackName,
+			StackResourceDriftStatusFilters: aws.StringSlice([]string{
+				cf.StackResourceDriftStatusModified,
+				cf.StackResourceDriftStatusDeleted,
+			}),
+		},
+		func(out *cf.DescribeStackResourceDriftsOutput, lastPage bool) bool {
+			for _, drift := range out.StackResourceDrifts {
+				if drift = g.unignoredDrift(drift); drift != nil {
+					drifts = append(drifts, drift)
+				}
+			}
+			return true
+		}); err != nil {
+		return nil, fmt.Errorf("DescribeStackResourceDrifts %s: %v", s.Name, err)
+	}
+
+	return drifts, nil
+}
+
+// unignoredDrift returns a copy of the drift without the property
+// differences the drift_ignore rules cover, or nil if they cover all of it.
+func (g GeneralConfig) unignoredDrift(drift *cf.StackResourceDrift) *cf.StackResourceDrift {
+	resourceType := aws.StringValue(drift.ResourceType)
+	logicalID := aws.StringValue(drift.LogicalResourceId)
+
+	if aws.StringValue(drift.StackResourceDriftStatus) == cf.StackResourceDriftStatusDeleted {
+		if g.IgnoreDrift(resourceType, logicalID, "") {
+			return nil
+		}
+		return drift
+	}
+
+	kept := *drift
+	kept.PropertyDifferences = nil
+	for _, diff := range drift.PropertyDifferences {
+		if !g.IgnoreDrift(resourceType, logicalID, aws.StringValue(diff.PropertyPath)) {
+			kept.PropertyDifferences = append(kept.PropertyDifferences, diff)
+		}
+	}
+
+	if len(kept.PropertyDifferences) == 0 {
+		return nil
+	}
+
+	return &kept
+}
+
+// DetectDrift starts drift detection on the stack and waits for it to finish.
+// Each call to AWS is made under awshelpers.Ratelimit, but the semaphore is
+// not held while waiting between polls.
+func (s *StackConfig) DetectDrift(ctx context.Context) (*cf.DescribeStackDriftDetectionStatusOutput, error) {
+	region, err := s.Region()
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.GetClient()
+	if err != nil {
+		return nil, err
+	}
+	stackName := s.StackName()
+
+	var detection *cf.DetectStackDriftOutput
+	awshelpers.Ratelimit(ctx, region, func() {
+		detection, err = client.DetectStackDriftWithContext(ctx, &cf.DetectStackDriftInput{
+			StackName: &stackName,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("DetectStackDrift %s: %v", s.Name, err)
+	} else if detection == nil {
+		return nil, fmt.Errorf("DetectStackDrift %s: %v", s.Name, ctx.Err())
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for drift detection on %s: %v", s.Name, ctx.Err())
+		case <-time.After(driftPollInterval):
+		}
+
+		var status *cf.DescribeStackDriftDetectionStatusOutput
+		awshelpers.Ratelimit(ctx, region, func() {
+			status, err = client.DescribeStackDriftDetectionStatusWithContext(ctx, &cf.DescribeStackDriftDetectionStatusInput{
+				StackDriftDetectionId: detection.StackDriftDetectionId,
+			})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("DescribeStackDriftDetectionStatus %s: %v", s.Name, err)
+		} else if status == nil {
+			continue
+		}
+
+		switch aws.StringValue(status.DetectionStatus) {
+		case cf.StackDriftDetectionStatusDetectionInProgress:
+			continue
+		case cf.StackDriftDetectionStatusDetectionFailed:
+			s.log.Warningf("drift detection on %s failed: %s", s.Name, aws.StringValue(status.DetectionStatusReason))
+		}
+
+		return status, nil
+	}
+}
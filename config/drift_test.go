@@ -0,0 +1,147 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	cf "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func TestDriftIgnoreRuleMatches(t *testing.T) {
+	const asg = "AWS::AutoScaling::AutoScalingGroup"
+
+	tests := []struct {
+		name         string
+		rule         DriftIgnoreRule
+		resourceType string
+		logicalID    string
+		propertyPath string
+		want         bool
+	}{
+		{"empty rule matches anything", DriftIgnoreRule{}, asg, "Group", "/DesiredCapacity", true},
+		{"type", DriftIgnoreRule{ResourceType: asg}, asg, "Group", "/DesiredCapacity", true},
+		{"other type", DriftIgnoreRule{ResourceType: asg}, "AWS::SQS::Queue", "Group", "/DesiredCapacity", false},
+		{"logical ID", DriftIgnoreRule{LogicalID: "Group"}, asg, "Group", "/MaxSize", true},
+		{"other logical ID", DriftIgnoreRule{LogicalID: "Group"}, asg, "Other", "/MaxSize", false},
+		{"exact path", DriftIgnoreRule{PropertyPath: "/DesiredCapacity"}, asg, "Group", "/DesiredCapacity", true},
+		{"path beneath", DriftIgnoreRule{PropertyPath: "/Tags"}, asg, "Group", "/Tags/0/Value", true},
+		{"path beneath with trailing slash", DriftIgnoreRule{PropertyPath: "/Tags/"}, asg, "Group", "/Tags/0/Value", true},
+		{"path sharing a prefix", DriftIgnoreRule{PropertyPath: "/Tag"}, asg, "Group", "/Tags/0/Value", false},
+		{"parent path", DriftIgnoreRule{PropertyPath: "/Tags/0"}, asg, "Group", "/Tags", false},
+		{"path on a deleted resource", DriftIgnoreRule{PropertyPath: "/Tags"}, asg, "Group", "", false},
+		{
+			name:         "all fields",
+			rule:         DriftIgnoreRule{ResourceType: asg, LogicalID: "Group", PropertyPath: "/DesiredCapacity"},
+			resourceType: asg,
+			logicalID:    "Group",
+			propertyPath: "/DesiredCapacity",
+			want:         true,
+		},
+		{
+			name:         "all fields, one differs",
+			rule:         DriftIgnoreRule{ResourceType: asg, LogicalID: "Group", PropertyPath: "/DesiredCapacity"},
+			resourceType: asg,
+			logicalID:    "Other",
+			propertyPath: "/DesiredCapacity",
+			want:         false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Matches(tt.resourceType, tt.logicalID, tt.propertyPath); got != tt.want {
+				t.Errorf("Matches(%q, %q, %q) = %v, want %v", tt.resourceType, tt.logicalID, tt.propertyPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreDrift(t *testing.T) {
+	g := GeneralConfig{DriftIgnore: []*DriftIgnoreRule{
+		nil,
+		{ResourceType: "AWS::AutoScaling::AutoScalingGroup", PropertyPath: "/DesiredCapacity"},
+		{LogicalID: "Queue"},
+	}}
+
+	tests := []struct {
+		resourceType string
+		logicalID    string
+		propertyPath string
+		want         bool
+	}{
+		{"AWS::AutoScaling::AutoScalingGroup", "Group", "/DesiredCapacity", true},
+		{"AWS::AutoScaling::AutoScalingGroup", "Group", "/MaxSize", false},
+		{"AWS::SQS::Queue", "Queue", "/DelaySeconds", true},
+		{"AWS::SQS::Queue", "Other", "/DelaySeconds", false},
+	}
+
+	for _, tt := range tests {
+		if got := g.IgnoreDrift(tt.resourceType, tt.logicalID, tt.propertyPath); got != tt.want {
+			t.Errorf("IgnoreDrift(%q, %q, %q) = %v, want %v", tt.resourceType, tt.logicalID, tt.propertyPath, got, tt.want)
+		}
+	}
+
+	if (GeneralConfig{}).IgnoreDrift("AWS::SQS::Queue", "Queue", "") {
+		t.Error("IgnoreDrift() without rules = true, want false")
+	}
+}
+
+func TestUnignoredDrift(t *testing.T) {
+	g := GeneralConfig{DriftIgnore: []*DriftIgnoreRule{
+		{ResourceType: "AWS::AutoScaling::AutoScalingGroup", PropertyPath: "/DesiredCapacity"},
+		{LogicalID: "Scratch"},
+	}}
+
+	drift := func(logicalID, status string, paths ...string) *cf.StackResourceDrift {
+		d := &cf.StackResourceDrift{
+			ResourceType:             aws.String("AWS::AutoScaling::AutoScalingGroup"),
+			LogicalResourceId:        aws.String(logicalID),
+			StackResourceDriftStatus: aws.String(status),
+		}
+		for _, p := range paths {
+			d.PropertyDifferences = append(d.PropertyDifferences, &cf.PropertyDifference{PropertyPath: aws.String(p)})
+		}
+		return d
+	}
+
+	tests := []struct {
+		name      string
+		drift     *cf.StackResourceDrift
+		wantPaths []string
+		wantNil   bool
+	}{
+		{"all ignored", drift("Group", cf.StackResourceDriftStatusModified, "/DesiredCapacity"), nil, true},
+		{"some ignored", drift("Group", cf.StackResourceDriftStatusModified, "/DesiredCapacity", "/MaxSize"), []string{"/MaxSize"}, false},
+		{"none ignored", drift("Group", cf.StackResourceDriftStatusModified, "/MaxSize"), []string{"/MaxSize"}, false},
+		{"deleted", drift("Group", cf.StackResourceDriftStatusDeleted), nil, false},
+		{"deleted and ignored", drift("Scratch", cf.StackResourceDriftStatusDeleted), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := g.unignoredDrift(tt.drift)
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("unignoredDrift() = %v, want nil %v", got, tt.wantNil)
+			}
+			if got == nil {
+				return
+			}
+
+			var paths []string
+			for _, diff := range got.PropertyDifferences {
+				paths = append(paths, aws.StringValue(diff.PropertyPath))
+			}
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("unignoredDrift() paths = %v, want %v", paths, tt.wantPaths)
+			}
+		})
+	}
+
+	// The original drift is left as it was
+	d := drift("Group", cf.StackResourceDriftStatusModified, "/DesiredCapacity", "/MaxSize")
+	g.unignoredDrift(d)
+	if len(d.PropertyDifferences) != 2 {
+		t.Errorf("unignoredDrift() modified the drift passed to it")
+	}
+}
@@ -22,8 +22,28 @@ type GeneralConfig struct {
 	CloudFormationRoot string `json:"cloud_formation_root" yaml:"cloud_formation_root"`
 	CacheDir           string `json:"cache" yaml:"cache"`
 
+	// ProtectedTypes are the resource types which may not be removed or
+	// replaced without being explicitly allowed. Defaults to
+	// DefaultProtectedTypes.
+	ProtectedTypes []string `json:"protected_types" yaml:"protected_types"`
+
+	// TemplateBucket is where templates are uploaded when they are too large
+	// to send to CloudFormation directly. TemplateBuckets overrides it per
+	// region.
+	TemplateBucket        string                     `json:"template_bucket" yaml:"template_bucket"`
+	TemplatePrefix        string                     `json:"template_prefix" yaml:"template_prefix"`
+	TemplateBuckets       map[string]*TemplateBucket `json:"template_buckets" yaml:"template_buckets"`
+	AlwaysUploadTemplates bool                       `json:"always_upload_templates" yaml:"always_upload_templates"`
+
+	// DriftIgnore lists drift which is expected, and so isn't reported by
+	// `drift`.
+	DriftIgnore []*DriftIgnoreRule `json:"drift_ignore" yaml:"drift_ignore"`
+
 	LogLevel logrus.Level   `json:"log_level" yaml:"log_level"`
 	Log      *logrus.Logger `json:"-" yaml:"-"`
+
+	// Format is how listings are printed, set by the global -format flag.
+	Format string `json:"-" yaml:"-"`
 }
 
 func LoadConfig() (*GeneralConfig, error) {
@@ -48,6 +68,9 @@ func LoadConfig() (*GeneralConfig, error) {
 	if generalConfig.CacheDir == "" {
 		generalConfig.CacheDir = helpers.Expand(DefaultCacheDir)
 	}
+	if generalConfig.ProtectedTypes == nil {
+		generalConfig.ProtectedTypes = DefaultProtectedTypes
+	}
 
 	generalConfig.CloudFormationRoot, err = homedir.Expand(generalConfig.CloudFormationRoot)
 	if err != nil {
@@ -81,6 +104,11 @@ func (g *GeneralConfig) LoadStacks() (*StacksDB, error) {
 			return err
 		}
 
+		// The configs of deleted stacks are kept out of the way
+		if info.IsDir() && path == g.ArchiveDir() {
+			return filepath.SkipDir
+		}
+
 		// Don't re-parse the config file
 		if filepath.Base(path) == config {
 			return nil
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryTimeFormat is the format of the timestamps history entries are
+// stored and looked up by.
+const HistoryTimeFormat = "20060102T150405Z"
+
+// HistoryEntry is a live template, and the parameters the stack was using,
+// as fetched at a point in time.
+type HistoryEntry struct {
+	Name      string            `json:"name"`
+	ARN       string            `json:"arn"`
+	Hash      string            `json:"hash"`
+	FetchedAt time.Time         `json:"fetched_at"`
+	Params    map[string]string `json:"params"`
+	Template  string            `json:"template"`
+}
+
+// Timestamp is the time the entry was fetched, in HistoryTimeFormat.
+func (h HistoryEntry) Timestamp() string {
+	return h.FetchedAt.UTC().Format(HistoryTimeFormat)
+}
+
+func (s *StackConfig) historyDir() (string, error) {
+	region, err := s.Region()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(s.cacheDir, "history", region, s.StackName()), nil
+}
+
+// RecordHistory stores the original live template, along with the live
+// parameters, unless they are the same as the most recent entry. It is called
+// explicitly by the commands which fetch the live template, with the
+// parameters they already have, so fetching a template has no side effects.
+func (s *StackConfig) RecordHistory(template string, params map[string]string) error {
+	dir, err := s.historyDir()
+	if err != nil {
+		return err
+	}
+
+	entry := &HistoryEntry{
+		Name:      s.Name,
+		ARN:       s.ARN,
+		Hash:      s.TemplateHash(template),
+		FetchedAt: time.Now(),
+		Params:    params,
+		Template:  template,
+	}
+
+	last, err := latestHistoryFile(dir)
+	if err != nil {
+		return err
+	}
+	// Only the latest entry needs reading, and only if the template matches
+	if last != "" && strings.HasSuffix(last, "-"+entry.Hash+".json") {
+		previous, err := loadHistoryEntry(filepath.Join(dir, last))
+		if err != nil {
+			return err
+		}
+		if len(ChangedParams(previous.Params, entry.Params)) == 0 {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	location := filepath.Join(dir, fmt.Sprintf("%s-%s.json", entry.Timestamp(), entry.Hash))
+	f, err := os.OpenFile(location, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(entry)
+}
+
+// History returns every stored version of the live template, oldest first.
+func (s *StackConfig) History() ([]*HistoryEntry, error) {
+	dir, err := s.historyDir()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	history := []*HistoryEntry{}
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		entry, err := loadHistoryEntry(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].FetchedAt.Before(history[j].FetchedAt)
+	})
+
+	return history, nil
+}
+
+// FindHistory finds the entry whose hash or timestamp starts with ref. If
+// several entries match, the most recent is returned as long as they all have
+// the same template.
+func (s *StackConfig) FindHistory(ref string) (*HistoryEntry, error) {
+	history, err := s.History()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *HistoryEntry
+	for _, entry := range history {
+		if !strings.HasPrefix(entry.Hash, ref) && !strings.HasPrefix(entry.Timestamp(), ref) {
+			continue
+		}
+
+		if found != nil && found.Hash != entry.Hash {
+			return nil, fmt.Errorf("%q matches more than one version of %s", ref, s.Name)
+		}
+		found = entry
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("no version of %s matching %q", s.Name, ref)
+	}
+
+	return found, nil
+}
+
+// latestHistoryFile returns the name of the most recent entry in dir, or an
+// empty string if there are none. Entries are named
+// "<timestamp>-<hash>.json" with timestamps in HistoryTimeFormat, which sort
+// in the order they were fetched.
+func latestHistoryFile(dir string) (string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	latest := ""
+	for _, file := range files {
+		if filepath.Ext(file.Name()) == ".json" && file.Name() > latest {
+			latest = file.Name()
+		}
+	}
+
+	return latest, nil
+}
+
+func loadHistoryEntry(file string) (*HistoryEntry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entry := &HistoryEntry{}
+	if err := json.NewDecoder(f).Decode(entry); err != nil {
+		return nil, fmt.Errorf("reading %q: %v", file, err)
+	}
+
+	return entry, nil
+}
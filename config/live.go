@@ -0,0 +1,49 @@
+package config
+
+import (
+	"context"
+	"fmt"
+
+	cf "github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/keyneston/cftool/awshelpers"
+)
+
+// LiveStackStatuses returns every stack status except DELETE_COMPLETE, for
+// listing only the stacks which still exist.
+func LiveStackStatuses() []string {
+	statuses := []string{}
+	for _, status := range cf.StackStatus_Values() {
+		if status != cf.StackStatusDeleteComplete {
+			statuses = append(statuses, status)
+		}
+	}
+
+	return statuses
+}
+
+// ListStacks lists the stacks in the region with one of the statuses, or
+// every stack, including those deleted in the last 90 days, if statuses is
+// empty.
+func (g GeneralConfig) ListStacks(ctx context.Context, region string, statuses []string) ([]*cf.StackSummary, error) {
+	client := awshelpers.GetCloudFormationClient(region)
+
+	input := &cf.ListStacksInput{}
+	for i := range statuses {
+		input.StackStatusFilter = append(input.StackStatusFilter, &statuses[i])
+	}
+
+	summaries := []*cf.StackSummary{}
+	var err error
+	awshelpers.Ratelimit(ctx, region, func() {
+		err = client.ListStacksPagesWithContext(ctx, input,
+			func(res *cf.ListStacksOutput, lastPage bool) bool {
+				summaries = append(summaries, res.StackSummaries...)
+				return true
+			})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ListStacks %s: %v", region, err)
+	}
+
+	return summaries, nil
+}
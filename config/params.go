@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	cf "github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/keyneston/cftool/helpers"
+)
+
+// maskedParamValue is what CloudFormation returns in place of the value of a
+// NoEcho parameter.
+const maskedParamValue = "****"
+
+// ChangedParams returns the sorted keys which differ between the two sets of
+// parameters.
+func ChangedParams(a, b map[string]string) []string {
+	changed := []string{}
+
+	for k, v := range a {
+		if other, ok := b[k]; !ok || other != v {
+			changed = append(changed, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed
+}
+
+// HashParams creates a hash of the parameters which is stable regardless of
+// the order of the keys.
+func HashParams(params map[string]string) string {
+	keys := []string{}
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%q=%q", k, params[k]))
+	}
+
+	return helpers.HashString(strings.Join(pairs, "\n"))
+}
+
+// GetLiveParams fetches the parameters the live stack is using.
+func (s *StackConfig) GetLiveParams() (map[string]string, error) {
+	live, err := s.GetLive()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(live.Stacks) == 0 {
+		return map[string]string{}, nil
+	}
+
+	return StackParams(live.Stacks[0]), nil
+}
+
+// StackParams returns the parameters of an already described stack.
+func StackParams(stack *cf.Stack) map[string]string {
+	params := map[string]string{}
+	for _, pair := range stack.Parameters {
+		if pair.ParameterKey != nil && pair.ParameterValue != nil {
+			params[*pair.ParameterKey] = *pair.ParameterValue
+		}
+	}
+
+	return params
+}
+
+// ChangedParamsFrom compares the parameters on disk to the already fetched
+// parameters of the live stack and returns the sorted keys which differ. Only
+// the keys on disk are compared, so a stack without params on disk never has
+// changed parameters. NoEcho parameters can't be compared, so they are never
+// reported as changed.
+func (s *StackConfig) ChangedParamsFrom(live map[string]string) []string {
+	changed := []string{}
+	for k, v := range s.Params {
+		if v == maskedParamValue || live[k] == maskedParamValue {
+			continue
+		}
+
+		if other, ok := live[k]; !ok || other != v {
+			changed = append(changed, k)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestChangedParams(t *testing.T) {
+	a := map[string]string{"A": "1", "B": "2", "C": "3"}
+	b := map[string]string{"A": "1", "B": "20", "D": "4"}
+
+	want := []string{"B", "C", "D"}
+	if got := ChangedParams(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedParams() = %v, want %v", got, want)
+	}
+}
+
+func TestChangedParamsFrom(t *testing.T) {
+	tests := []struct {
+		name string
+		disk map[string]string
+		live map[string]string
+		want []string
+	}{
+		{
+			name: "no params on disk",
+			disk: nil,
+			live: map[string]string{"A": "1"},
+			want: []string{},
+		},
+		{
+			name: "unchanged",
+			disk: map[string]string{"A": "1"},
+			live: map[string]string{"A": "1", "B": "2"},
+			want: []string{},
+		},
+		{
+			name: "changed and added",
+			disk: map[string]string{"A": "10", "B": "2", "C": "3"},
+			live: map[string]string{"A": "1", "B": "2"},
+			want: []string{"A", "C"},
+		},
+		{
+			name: "masked live",
+			disk: map[string]string{"Password": "secret"},
+			live: map[string]string{"Password": maskedParamValue},
+			want: []string{},
+		},
+		{
+			name: "masked on disk",
+			disk: map[string]string{"Password": maskedParamValue},
+			live: map[string]string{"Password": "secret"},
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &StackConfig{Params: tt.disk}
+			if got := s.ChangedParamsFrom(tt.live); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChangedParamsFrom() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAWSParams(t *testing.T) {
+	s := &StackConfig{Params: map[string]string{
+		"Size":     "large",
+		"Password": "secret",
+		"Token":    maskedParamValue,
+	}}
+	live := map[string]string{
+		"Size":     "small",
+		"Password": maskedParamValue,
+		"Token":    maskedParamValue,
+		"Unset":    "kept",
+	}
+
+	// Each key's value, or "previous" if it keeps its previous value
+	got := map[string]string{}
+	keys := []string{}
+	for _, p := range s.AWSParams(live) {
+		key := aws.StringValue(p.ParameterKey)
+		keys = append(keys, key)
+
+		switch {
+		case aws.BoolValue(p.UsePreviousValue) && p.ParameterValue == nil:
+			got[key] = "previous"
+		case !aws.BoolValue(p.UsePreviousValue) && p.ParameterValue != nil:
+			got[key] = aws.StringValue(p.ParameterValue)
+		default:
+			t.Errorf("%s has both a value and UsePreviousValue set", key)
+		}
+	}
+
+	want := map[string]string{
+		"Size":     "large",
+		"Password": "previous",
+		"Token":    "previous",
+		"Unset":    "previous",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AWSParams() = %v, want %v", got, want)
+	}
+
+	sort.Strings(keys)
+	if want := []string{"Password", "Size", "Token", "Unset"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("AWSParams() keys = %v, want each of %v once", keys, want)
+	}
+}
+
+func TestHashParams(t *testing.T) {
+	a := HashParams(map[string]string{"A": "1", "B": "2"})
+	b := HashParams(map[string]string{"B": "2", "A": "1"})
+	if a != b {
+		t.Errorf("HashParams() depends on the order of the keys")
+	}
+
+	if c := HashParams(map[string]string{"A": "1", "B": "3"}); c == a {
+		t.Errorf("HashParams() = %s for different params", c)
+	}
+}
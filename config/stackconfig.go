@@ -17,6 +17,7 @@ import (
 	cf "github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/keyneston/cftool/awshelpers"
+	"github.com/keyneston/cftool/cfntemplate"
 	"github.com/keyneston/cftool/helpers"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
@@ -31,6 +32,17 @@ type StackConfig struct {
 	Source   string                       `json:"source" yaml:"-"`
 	Hydrated bool                         `json:"-" yaml:"-"`
 
+	// AllowDestructive lists the logical IDs or resource types which may be
+	// removed or replaced even if they are protected.
+	AllowDestructive []string `json:"allow_destructive,omitempty" yaml:"allow_destructive,omitempty"`
+
+	// Capabilities overrides the capabilities worked out from the template.
+	Capabilities []string `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+
+	// TemplateStage is the stage of the live template diff-template compares
+	// against by default: original or processed.
+	TemplateStage string `json:"template_stage,omitempty" yaml:"template_stage,omitempty"`
+
 	client    *cf.CloudFormation
 	parsedARN arn.ARN
 	stackName string
@@ -85,7 +97,15 @@ func (s *StackConfig) GetASGClient() (*autoscaling.AutoScaling, error) {
 	return awshelpers.GetASGClient(region), nil
 }
 
+// GetLiveTemplate fetches the template of the live stack as it was submitted.
 func (s *StackConfig) GetLiveTemplate() (string, error) {
+	return s.GetLiveTemplateStage(cf.TemplateStageOriginal)
+}
+
+// GetLiveTemplateStage fetches the template of the live stack at the given
+// stage, either as it was submitted (Original) or after transforms and macros
+// have been applied (Processed).
+func (s *StackConfig) GetLiveTemplateStage(stage string) (string, error) {
 	client, err := s.GetClient()
 	if err != nil {
 		return "", err
@@ -93,17 +113,30 @@ func (s *StackConfig) GetLiveTemplate() (string, error) {
 
 	template, err := client.GetTemplate(&cf.GetTemplateInput{
 		StackName:     &s.stackName,
-		TemplateStage: aws.String("Original"),
+		TemplateStage: &stage,
 	})
 	if err != nil {
 		return "", fmt.Errorf("GetTemplate: %v", err)
 	}
 
-	if template.TemplateBody != nil {
-		return *template.TemplateBody, nil
-	} else {
+	if template.TemplateBody == nil {
 		return "", fmt.Errorf("no template found")
 	}
+
+	return *template.TemplateBody, nil
+}
+
+// ParseTemplateStage converts a stage given by the user, in any case, to the
+// stage CloudFormation expects. An empty stage is Original.
+func ParseTemplateStage(stage string) (string, error) {
+	switch strings.ToLower(stage) {
+	case "", "original":
+		return cf.TemplateStageOriginal, nil
+	case "processed":
+		return cf.TemplateStageProcessed, nil
+	}
+
+	return "", fmt.Errorf("invalid template stage %q, must be original or processed", stage)
 }
 
 func (s *StackConfig) GetLive() (*cf.DescribeStacksOutput, error) {
@@ -248,9 +281,8 @@ func (s StackConfig) GetLiveTemplateHash() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	liveTemplateHash := helpers.HashString(template)
 
-	return liveTemplateHash, nil
+	return s.TemplateHash(template), nil
 }
 
 func (s StackConfig) GetDiskTemplateLocation() string {
@@ -258,7 +290,25 @@ func (s StackConfig) GetDiskTemplateLocation() string {
 }
 
 func (s StackConfig) GetDiskTemplateHash() (string, error) {
-	return helpers.HashFile(s.GetDiskTemplateLocation())
+	template, err := s.GetDiskTemplate()
+	if err != nil {
+		return "", fmt.Errorf("error hashing %q: %v", s.GetDiskTemplateLocation(), err)
+	}
+
+	return s.TemplateHash(template), nil
+}
+
+// TemplateHash returns the canonical hash of the template, so that
+// differences in formatting aren't seen as changes. If the template can't be
+// parsed the raw contents are hashed instead.
+func (s StackConfig) TemplateHash(template string) string {
+	hash, err := cfntemplate.CanonicalHash(template)
+	if err != nil {
+		s.log.Debugf("%s: falling back to hashing the raw template: %v", s.Name, err)
+		return helpers.HashString(template)
+	}
+
+	return hash
 }
 
 func (s StackConfig) GetDiskTemplate() (string, error) {
@@ -276,6 +326,26 @@ func (s StackConfig) GetDiskTemplate() (string, error) {
 	return string(data), nil
 }
 
+// GetCapabilities returns the capabilities needed to update the stack with the
+// disk template, unless they are overridden in the stack config.
+func (s StackConfig) GetCapabilities() ([]string, error) {
+	if len(s.Capabilities) != 0 {
+		return s.Capabilities, nil
+	}
+
+	body, err := s.GetDiskTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := cfntemplate.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", s.GetDiskTemplateLocation(), err)
+	}
+
+	return template.Capabilities(), nil
+}
+
 func (s *StackConfig) StackName() string {
 	if err := s.parseARN(); err != nil {
 		return ""
@@ -284,10 +354,23 @@ func (s *StackConfig) StackName() string {
 	return s.stackName
 }
 
-func (s *StackConfig) AWSParams() []*cloudformation.Parameter {
+// AWSParams converts the parameters on disk for a changeset. NoEcho
+// parameters, whose value is masked either on disk or in the live
+// parameters, keep their previous value rather than being set to the mask.
+// Live parameters missing from disk also keep their previous value, as diff
+// doesn't report them as changed.
+func (s *StackConfig) AWSParams(live map[string]string) []*cloudformation.Parameter {
 	awsParams := []*cloudformation.Parameter{}
 
 	for k, v := range s.Params {
+		if v == maskedParamValue || live[k] == maskedParamValue {
+			awsParams = append(awsParams, &cloudformation.Parameter{
+				ParameterKey:     aws.String(k),
+				UsePreviousValue: aws.Bool(true),
+			})
+			continue
+		}
+
 		awsParams = append(awsParams, &cloudformation.Parameter{
 			// Use aws.String to clone and then take a pointer to the clone:
 			ParameterKey:   aws.String(k),
@@ -295,6 +378,17 @@ func (s *StackConfig) AWSParams() []*cloudformation.Parameter {
 		})
 	}
 
+	for k := range live {
+		if _, ok := s.Params[k]; ok {
+			continue
+		}
+
+		awsParams = append(awsParams, &cloudformation.Parameter{
+			ParameterKey:     aws.String(k),
+			UsePreviousValue: aws.Bool(true),
+		})
+	}
+
 	return awsParams
 }
 
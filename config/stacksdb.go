@@ -7,7 +7,7 @@ import (
 )
 
 type StacksDB struct {
-	All    []*StackConfig
+	All    []*StackConfig `json:"all" yaml:"all"`
 	byName map[string]*StackConfig
 	byARN  map[string]*StackConfig
 
@@ -25,11 +25,11 @@ func (s *StacksDB) AddStack(stacks ...*StackConfig) {
 
 	for _, stack := range stacks {
 		if _, ok := s.byARN[stack.ARN]; ok {
-			s.log.Warning("Already added %q skipping", stack.Name)
+			s.log.Warningf("Already added %q skipping", stack.Name)
 			continue
 		}
 		if _, ok := s.byName[stack.Name]; ok {
-			s.log.Warning("Already added %q skipping", stack.Name)
+			s.log.Warningf("Already added %q skipping", stack.Name)
 			continue
 		}
 
@@ -50,7 +50,7 @@ func (s *StacksDB) FindByARN(name string) *StackConfig {
 	return s.byARN[name]
 }
 
-func (s StacksDB) Len() int {
+func (s *StacksDB) Len() int {
 	return len(s.All)
 }
 
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/keyneston/cftool/awshelpers"
+	"github.com/keyneston/cftool/helpers"
+)
+
+// MaxTemplateBodySize is the largest template CloudFormation accepts as a
+// TemplateBody. Anything larger must be uploaded to S3.
+const MaxTemplateBodySize = 51200
+
+// TemplateBucket is an S3 location templates are uploaded to.
+type TemplateBucket struct {
+	Bucket string `json:"bucket" yaml:"bucket"`
+	Prefix string `json:"prefix" yaml:"prefix"`
+}
+
+// GetTemplateBucket returns where templates for stacks in the region should
+// be uploaded, or nil if no bucket is configured.
+func (g GeneralConfig) GetTemplateBucket(region string) *TemplateBucket {
+	if b, ok := g.TemplateBuckets[region]; ok && b != nil && b.Bucket != "" {
+		return b
+	}
+
+	if g.TemplateBucket == "" {
+		return nil
+	}
+
+	return &TemplateBucket{
+		Bucket: g.TemplateBucket,
+		Prefix: g.TemplatePrefix,
+	}
+}
+
+// Upload uploads the template keyed by the hash of its contents, and returns
+// the URL to pass to CloudFormation as a TemplateURL. If the template has
+// already been uploaded it isn't uploaded again. Without s3:ListBucket S3
+// reports a missing key as forbidden, so that is treated the same as not
+// found; as the key is the hash re-uploading is harmless.
+func (b TemplateBucket) Upload(ctx context.Context, template string) (string, error) {
+	region, err := s3manager.GetBucketRegion(ctx, awshelpers.GetSession(""), b.Bucket, "us-east-1")
+	if err != nil {
+		return "", fmt.Errorf("finding region of bucket %q: %v", b.Bucket, err)
+	}
+
+	key := path.Join(b.Prefix, helpers.HashString(template)+".template")
+	url := templateURL(b.Bucket, region, key)
+	client := awshelpers.GetS3Client(region)
+
+	_, err = client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: &b.Bucket,
+		Key:    &key,
+	})
+	if err == nil {
+		return url, nil
+	} else if aerr, ok := err.(awserr.RequestFailure); !ok || (aerr.StatusCode() != 404 && aerr.StatusCode() != 403) {
+		return "", fmt.Errorf("checking for s3://%s/%s: %v", b.Bucket, key, err)
+	}
+
+	if _, err := client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      &b.Bucket,
+		Key:         &key,
+		Body:        bytes.NewReader([]byte(template)),
+		ContentType: aws.String("text/plain"),
+	}); err != nil {
+		return "", fmt.Errorf("uploading to s3://%s/%s: %v", b.Bucket, key, err)
+	}
+
+	return url, nil
+}
+
+// templateURL returns the URL of the object. Bucket names containing dots
+// don't match S3's wildcard certificate as a virtual hosted domain, so they
+// use the path style URL instead.
+func templateURL(bucket, region, key string) string {
+	if strings.Contains(bucket, ".") {
+		return fmt.Sprintf("https://s3.%s.amazonaws.com/%s/%s", region, bucket, key)
+	}
+
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, key)
+}
@@ -3,7 +3,7 @@ module github.com/keyneston/cftool
 go 1.15
 
 require (
-	github.com/aws/aws-sdk-go v1.35.11
+	github.com/aws/aws-sdk-go v1.36.0
 	github.com/dustin/go-humanize v1.0.0 // indirect
 	github.com/fatih/color v1.10.0
 	github.com/google/subcommands v1.2.0
@@ -11,11 +11,13 @@ require (
 	github.com/kataras/tablewriter v0.0.0-20180708051242-e063d29b7c23 // indirect
 	github.com/keyneston/tabslib v0.0.0-20200904124715-739c15d81515
 	github.com/lensesio/tableprinter v0.0.0-20200805134727-ea32388e35c1
-	github.com/mattn/go-runewidth v0.0.9 // indirect
+	github.com/mattn/go-runewidth v0.0.9
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/pmezard/go-difflib v1.0.0
 	github.com/sirupsen/logrus v1.7.0
 	golang.org/x/sync v0.0.0-20201020160332-67f06af15bc9
+	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f
 	google.golang.org/appengine v1.6.7
 	gopkg.in/yaml.v2 v2.3.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -5,6 +5,7 @@ import (
 	"log"
 
 	"github.com/google/subcommands"
+	"github.com/hashicorp/go-multierror"
 )
 
 // ExitFailure logs a message and returns the subcommands.ExitFailure. It is a wrapper to prevent writing the following all over the place:
@@ -21,3 +22,17 @@ func ExitErr(err error) subcommands.ExitStatus {
 	log.Printf("Error: %v", err)
 	return subcommands.ExitFailure
 }
+
+// Errors splits apart a multierror so each error can be reported separately.
+// Any other error is returned on its own.
+func Errors(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	if merr, ok := err.(*multierror.Error); ok {
+		return merr.Errors
+	}
+
+	return []error{err}
+}
@@ -9,13 +9,18 @@ import (
 	"time"
 
 	"github.com/google/subcommands"
+	"github.com/keyneston/cftool/cmds/apply"
+	"github.com/keyneston/cftool/cmds/changesets"
 	"github.com/keyneston/cftool/cmds/configcmd"
 	"github.com/keyneston/cftool/cmds/diff"
 	"github.com/keyneston/cftool/cmds/difftemplate"
+	"github.com/keyneston/cftool/cmds/drift"
 	"github.com/keyneston/cftool/cmds/fetch"
+	"github.com/keyneston/cftool/cmds/history"
 	"github.com/keyneston/cftool/cmds/sshcmd"
 	"github.com/keyneston/cftool/cmds/status"
 	"github.com/keyneston/cftool/config"
+	"github.com/keyneston/cftool/output"
 	"github.com/sirupsen/logrus"
 )
 
@@ -30,7 +35,11 @@ func registerSubcommands(general *config.GeneralConfig, stacks *config.StacksDB)
 	subcommands.Register(&fetch.FetchStacks{StacksDB: stacks, General: general}, "")
 	subcommands.Register(&configcmd.PrintConfig{StacksDB: stacks, General: general}, "")
 	subcommands.Register(&diff.DiffStacks{StacksDB: stacks, General: general}, "")
+	subcommands.Register(&apply.ApplyPlan{StacksDB: stacks, General: general}, "")
+	subcommands.Register(&changesets.ChangeSets{StacksDB: stacks, General: general}, "")
 	subcommands.Register(&difftemplate.DiffTemplate{StacksDB: stacks, General: general}, "")
+	subcommands.Register(&history.History{StacksDB: stacks, General: general}, "")
+	subcommands.Register(&drift.Drift{StacksDB: stacks, General: general}, "")
 	subcommands.Register(&sshcmd.SSHcmd{StacksDB: stacks, General: general}, "")
 }
 
@@ -39,16 +48,25 @@ func main() {
 	rand.Seed(time.Now().UnixNano())
 
 	shouldDebug := false
+	format := output.Table
 
 	flag.BoolVar(&shouldDebug, "debug", shouldDebug, "enable debug output")
+	flag.StringVar(&format, "format", format, "how to print listings: table, json, yaml, or csv")
 	flag.Parse()
 
+	if err := output.CheckFormat(format); err != nil {
+		log.Printf("Error: %v", err)
+		os.Exit(-1)
+	}
+
 	generalConfig, err := config.LoadConfig()
 	if err != nil {
 		log.Printf("Error loading config: %v", err)
 		os.Exit(-1)
 	}
 
+	generalConfig.Format = format
+
 	if shouldDebug {
 		generalConfig.SetLevel(logrus.DebugLevel)
 		// TODO: set it to include line numbers
@@ -0,0 +1,367 @@
+// Package output prints the listings commands produce, as a table for people
+// or as JSON, YAML, or CSV for scripts.
+//
+// Listings are slices of structs. The table shows the fields with a `header`
+// tag, under that header. The other formats show the fields with a `json`
+// tag, keyed by it, so the field names stay stable however the table is laid
+// out.
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/lensesio/tableprinter"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats listings can be printed in.
+const (
+	Table = "table"
+	JSON  = "json"
+	YAML  = "yaml"
+	CSV   = "csv"
+)
+
+// Formats lists every format, in the order they are documented.
+var Formats = []string{Table, JSON, YAML, CSV}
+
+// CheckFormat returns an error if format isn't one of Formats.
+func CheckFormat(format string) error {
+	for _, f := range Formats {
+		if format == f {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid format %q, must be one of %s", format, strings.Join(Formats, ", "))
+}
+
+// Printer prints listings in Format. If Columns are given only they are
+// printed, in that order.
+type Printer struct {
+	Format  string
+	Columns []string
+}
+
+// New creates a Printer for the format, defaulting to Table.
+func New(format string, columns ...string) *Printer {
+	if format == "" {
+		format = Table
+	}
+
+	return &Printer{Format: format, Columns: columns}
+}
+
+// IsTable reports whether the listing is printed for people rather than
+// scripts, so it can be decorated, e.g. with colour.
+func (p *Printer) IsTable() bool {
+	return p.Format == Table
+}
+
+// Check returns an error if the format or columns are invalid for the
+// entries, so it can be reported before doing any work.
+func (p *Printer) Check(entries interface{}) error {
+	if err := CheckFormat(p.Format); err != nil {
+		return err
+	}
+
+	_, err := p.fields(reflect.TypeOf(entries))
+	return err
+}
+
+// Print writes the entries, which must be a slice of structs.
+func (p *Printer) Print(w io.Writer, entries interface{}) error {
+	v := reflect.ValueOf(entries)
+
+	fields, err := p.fields(v.Type())
+	if err != nil {
+		return err
+	}
+
+	switch p.Format {
+	case Table:
+		return printTable(w, v, fields)
+	case JSON:
+		return printJSON(w, records(v, fields))
+	case YAML:
+		return printYAML(w, records(v, fields))
+	case CSV:
+		return printCSV(w, v, fields)
+	}
+
+	return CheckFormat(p.Format)
+}
+
+// PrintValue writes a single value which isn't a listing, such as the config,
+// as JSON or YAML. Table and CSV aren't supported; callers print their own
+// table.
+func (p *Printer) PrintValue(w io.Writer, value interface{}) error {
+	switch p.Format {
+	case JSON:
+		return printJSON(w, value)
+	case YAML:
+		return printYAML(w, value)
+	}
+
+	return fmt.Errorf("can't print as %s", p.Format)
+}
+
+// field is a column of the listing.
+type field struct {
+	index  int
+	header string
+	key    string
+}
+
+// fields returns the columns to print from the type of the entries.
+func (p *Printer) fields(typ reflect.Type) ([]field, error) {
+	if typ == nil || typ.Kind() != reflect.Slice || typ.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("output: expected a slice of structs, got %v", typ)
+	}
+	typ = typ.Elem()
+
+	all := []field{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		header := f.Tag.Get("header")
+		if header == "-" {
+			header = ""
+		}
+		key := strings.Split(f.Tag.Get("json"), ",")[0]
+		if key == "-" {
+			key = ""
+		}
+
+		if header == "" && key == "" {
+			continue
+		}
+		all = append(all, field{index: i, header: header, key: key})
+	}
+
+	if len(p.Columns) == 0 {
+		shown := []field{}
+		for _, f := range all {
+			if (p.IsTable() && f.header != "") || (!p.IsTable() && f.key != "") {
+				shown = append(shown, f)
+			}
+		}
+		return shown, nil
+	}
+
+	shown := []field{}
+	for _, c := range p.Columns {
+		f, ok := findColumn(all, c)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, must be one of %s", c, strings.Join(columnNames(all), ", "))
+		}
+		shown = append(shown, f)
+	}
+
+	return shown, nil
+}
+
+// ColumnName normalises a header or key into the name used to select its
+// column, so "stack status", "stack-status" and "stack_status" are the same.
+func ColumnName(name string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "-", "_", "-").Replace(strings.TrimSpace(name)))
+}
+
+func findColumn(fields []field, column string) (field, bool) {
+	name := ColumnName(column)
+	for _, f := range fields {
+		if (f.header != "" && ColumnName(f.header) == name) || (f.key != "" && ColumnName(f.key) == name) {
+			return f, true
+		}
+	}
+
+	return field{}, false
+}
+
+func columnNames(fields []field) []string {
+	names := []string{}
+	for _, f := range fields {
+		if f.header != "" {
+			names = append(names, ColumnName(f.header))
+		} else {
+			names = append(names, ColumnName(f.key))
+		}
+	}
+
+	return names
+}
+
+func printTable(w io.Writer, v reflect.Value, fields []field) error {
+	headers := []string{}
+	for _, f := range fields {
+		header := f.header
+		if header == "" {
+			header = f.key
+		}
+		headers = append(headers, header)
+	}
+
+	rows := [][]string{}
+	for i := 0; i < v.Len(); i++ {
+		row := []string{}
+		for _, f := range fields {
+			row = append(row, tableCell(v.Index(i).Field(f.index)))
+		}
+		rows = append(rows, row)
+	}
+
+	tableprinter.Render(w, headers, rows, nil, false)
+	return nil
+}
+
+func printCSV(w io.Writer, v reflect.Value, fields []field) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{}
+	for _, f := range fields {
+		header = append(header, key(f))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		row := []string{}
+		for _, f := range fields {
+			row = append(row, csvCell(v.Index(i).Field(f.index)))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func printJSON(w io.Writer, value interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
+
+func printYAML(w io.Writer, value interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(value); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// key is the name of the field in JSON, YAML, and CSV output.
+func key(f field) string {
+	if f.key != "" {
+		return f.key
+	}
+
+	return strings.ReplaceAll(ColumnName(f.header), "-", "_")
+}
+
+// record is an entry with only the chosen fields, which keeps them in order
+// when marshalled.
+type record []recordField
+
+type recordField struct {
+	key   string
+	value interface{}
+}
+
+func records(v reflect.Value, fields []field) []record {
+	out := []record{}
+	for i := 0; i < v.Len(); i++ {
+		r := record{}
+		for _, f := range fields {
+			r = append(r, recordField{key: key(f), value: v.Index(i).Field(f.index).Interface()})
+		}
+		out = append(out, r)
+	}
+
+	return out
+}
+
+func (r record) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, f := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		k, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func (r record) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range r {
+		value := &yaml.Node{}
+		if err := value.Encode(f.value); err != nil {
+			return nil, err
+		}
+
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: f.key}, value)
+	}
+
+	return node, nil
+}
+
+// tableCell formats a field the way tableprinter does: booleans as Yes or
+// No, and nil pointers as empty cells.
+func tableCell(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Bool {
+		if v.Bool() {
+			return "Yes"
+		}
+		return "No"
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+func csvCell(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	return fmt.Sprint(v.Interface())
+}
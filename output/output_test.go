@@ -0,0 +1,141 @@
+package output
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type entry struct {
+	Region   string `header:"aws region" json:"region"`
+	Name     string `header:"stack name" json:"stack_name"`
+	Drifted  *bool  `header:"drifted" json:"drifted"`
+	Table    string `header:"table only"`
+	Script   string `json:"script_only"`
+	Hidden   string `header:"-" json:"-"`
+	Untagged string
+
+	unexported string
+}
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		columns []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "table", format: Table, want: []string{"aws region", "stack name", "drifted", "table only"}},
+		{name: "json", format: JSON, want: []string{"region", "stack_name", "drifted", "script_only"}},
+		{name: "columns in order", format: Table, columns: []string{"drifted", "aws-region"}, want: []string{"drifted", "aws region"}},
+		{name: "column by key", format: Table, columns: []string{"stack_name", "Script-Only"}, want: []string{"stack name", "script_only"}},
+		{name: "column by header", format: CSV, columns: []string{"Stack Name", "table only"}, want: []string{"stack_name", "table_only"}},
+		{name: "unknown column", format: Table, columns: []string{"region-name"}, wantErr: true},
+		{name: "hidden column", format: JSON, columns: []string{"hidden"}, wantErr: true},
+		{name: "untagged column", format: JSON, columns: []string{"untagged"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := New(tt.format, tt.columns...).fields(reflect.TypeOf([]entry{}))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fields() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, f := range fields {
+				if tt.format == Table && f.header != "" {
+					got = append(got, f.header)
+				} else {
+					got = append(got, key(f))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if err := New(Table).Check([]entry{}); err != nil {
+		t.Errorf("Check() = %v, want nil", err)
+	}
+	if err := New("xml").Check([]entry{}); err == nil {
+		t.Error("Check() with an invalid format = nil, want an error")
+	}
+	if err := New(Table).Check(entry{}); err == nil {
+		t.Error("Check() of a struct = nil, want an error")
+	}
+}
+
+func TestPrint(t *testing.T) {
+	yes := true
+	entries := []entry{
+		{Region: "us-east-1", Name: "a", Drifted: &yes, Script: "x"},
+		{Region: "us-west-2", Name: "b, c"},
+	}
+
+	tests := []struct {
+		format  string
+		columns []string
+		want    string
+	}{
+		{
+			format: JSON,
+			want: `[
+  {
+    "region": "us-east-1",
+    "stack_name": "a",
+    "drifted": true,
+    "script_only": "x"
+  },
+  {
+    "region": "us-west-2",
+    "stack_name": "b, c",
+    "drifted": null,
+    "script_only": ""
+  }
+]
+`,
+		},
+		{
+			format:  YAML,
+			columns: []string{"stack-name", "region"},
+			want: `- stack_name: a
+  region: us-east-1
+- stack_name: b, c
+  region: us-west-2
+`,
+		},
+		{
+			format:  CSV,
+			columns: []string{"region", "stack name", "drifted", "table only"},
+			want: `region,stack_name,drifted,table_only
+us-east-1,a,true,
+us-west-2,"b, c",,
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := New(tt.format, tt.columns...).Print(buf, entries); err != nil {
+				t.Fatalf("Print() = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Print() wrote\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for _, name := range []string{"stack status", "stack-status", "stack_status", " Stack Status "} {
+		if got := ColumnName(name); got != "stack-status" {
+			t.Errorf("ColumnName(%q) = %q, want %q", name, got, "stack-status")
+		}
+	}
+}
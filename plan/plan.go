@@ -3,16 +3,93 @@ package plan
 import (
 	"encoding/json"
 	"os"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/keyneston/cftool/config"
 )
 
+// Plan is the record of the changesets created by `diff`, and is what `apply`
+// later executes.
 type Plan struct {
-	ChangeSetIDs []string `json:"change_set_ids"`
+	CreatedAt time.Time    `json:"created_at"`
+	Stacks    []*StackPlan `json:"stacks"`
 }
 
-func CreatePlan(file string, stack *config.StackConfig) (*Plan, error) {
-	return nil, nil
+// StackPlan records a single changeset, and the stack it belongs to.
+type StackPlan struct {
+	Name         string    `json:"name"`
+	StackARN     string    `json:"stack_arn"`
+	Region       string    `json:"region"`
+	ChangeSetARN string    `json:"change_set_arn"`
+	CreatedAt    time.Time `json:"created_at"`
+	Changes      []Change  `json:"changes"`
+
+	// The inputs the changeset was created from, used to verify the plan is
+	// still valid before applying it.
+	DiskTemplateHash string            `json:"disk_template_hash"`
+	LiveTemplateHash string            `json:"live_template_hash"`
+	Params           map[string]string `json:"params"`
+}
+
+// Change is a single resource change within a changeset.
+type Change struct {
+	Action      string `json:"action"`
+	Type        string `json:"type"`
+	LogicalID   string `json:"logical_id"`
+	Replacement string `json:"replacement"`
+	NestedStack string `json:"nested_stack,omitempty"`
+}
+
+// NewStackPlan creates a StackPlan from a stack and the calculated changeset.
+// liveHash is the hash of the live template the changeset was created
+// against.
+func NewStackPlan(stack *config.StackConfig, liveHash string, changeSet *cloudformation.DescribeChangeSetOutput, changes []Change) (*StackPlan, error) {
+	region, err := stack.Region()
+	if err != nil {
+		return nil, err
+	}
+
+	diskHash, err := stack.GetDiskTemplateHash()
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{}
+	for k, v := range stack.Params {
+		params[k] = v
+	}
+
+	return &StackPlan{
+		Name:         stack.Name,
+		StackARN:     aws.StringValue(changeSet.StackId),
+		Region:       region,
+		ChangeSetARN: aws.StringValue(changeSet.ChangeSetId),
+		CreatedAt:    aws.TimeValue(changeSet.CreationTime),
+		Changes:      changes,
+
+		DiskTemplateHash: diskHash,
+		LiveTemplateHash: liveHash,
+		Params:           params,
+	}, nil
+}
+
+// CreatePlan creates a plan out of the given stacks and saves it to file.
+func CreatePlan(file string, stacks ...*StackPlan) (*Plan, error) {
+	plan := &Plan{
+		CreatedAt: time.Now(),
+		Stacks:    stacks,
+	}
+	if plan.Stacks == nil {
+		plan.Stacks = []*StackPlan{}
+	}
+
+	if err := plan.Save(file); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
 }
 
 func LoadPlan(file string) (*Plan, error) {
@@ -31,7 +108,7 @@ func LoadPlan(file string) (*Plan, error) {
 }
 
 func (p Plan) Save(file string) error {
-	f, err := os.OpenFile(file, os.O_TRUNC|os.O_CREATE|os.O_RDWR, 0x644)
+	f, err := os.OpenFile(file, os.O_TRUNC|os.O_CREATE|os.O_RDWR, 0o644)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,66 @@
+package plan
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/keyneston/cftool/config"
+)
+
+// IsDestructive reports whether the change removes or replaces the resource.
+func (c Change) IsDestructive() bool {
+	return c.Action == "Remove" || c.Replacement == "True" || c.Replacement == "Conditional"
+}
+
+// DestructiveChanges returns the changes which would remove or replace a
+// resource of one of the protected types. Changes to resources whose logical
+// ID or type is in allowed are skipped.
+func DestructiveChanges(changes []Change, protected, allowed []string) []Change {
+	protectedTypes := toSet(protected)
+	allowedSet := toSet(allowed)
+
+	destructive := []Change{}
+	for _, change := range changes {
+		if !protectedTypes[change.Type] || !change.IsDestructive() {
+			continue
+		}
+		if allowedSet[change.LogicalID] || allowedSet[change.Type] {
+			continue
+		}
+
+		destructive = append(destructive, change)
+	}
+
+	return destructive
+}
+
+// CheckDestructive returns an error listing every destructive change to a
+// protected resource which the stack does not allow.
+func CheckDestructive(changes []Change, protected []string, stack *config.StackConfig) error {
+	result := &multierror.Error{}
+
+	for _, change := range DestructiveChanges(changes, protected, stack.AllowDestructive) {
+		verb := "replace"
+		if change.Action == "Remove" {
+			verb = "remove"
+		}
+
+		name := change.LogicalID
+		if change.NestedStack != "" {
+			name = change.NestedStack + "/" + name
+		}
+
+		result = multierror.Append(result, fmt.Errorf("would %s protected resource %s (%s)", verb, name, change.Type))
+	}
+
+	return result.ErrorOrNil()
+}
+
+func toSet(items []string) map[string]bool {
+	set := map[string]bool{}
+	for _, item := range items {
+		set[item] = true
+	}
+
+	return set
+}
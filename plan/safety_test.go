@@ -0,0 +1,121 @@
+package plan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/keyneston/cftool/config"
+)
+
+func TestIsDestructive(t *testing.T) {
+	tests := []struct {
+		change Change
+		want   bool
+	}{
+		{Change{Action: "Add"}, false},
+		{Change{Action: "Modify", Replacement: "False"}, false},
+		{Change{Action: "Modify", Replacement: "True"}, true},
+		{Change{Action: "Modify", Replacement: "Conditional"}, true},
+		{Change{Action: "Remove"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.change.IsDestructive(); got != tt.want {
+			t.Errorf("%+v.IsDestructive() = %v, want %v", tt.change, got, tt.want)
+		}
+	}
+}
+
+func TestDestructiveChanges(t *testing.T) {
+	const db = "AWS::RDS::DBInstance"
+	protected := []string{db, "AWS::DynamoDB::Table"}
+
+	replaceDB := Change{Action: "Modify", Type: db, LogicalID: "Database", Replacement: "True"}
+	removeDB := Change{Action: "Remove", Type: db, LogicalID: "Database"}
+	nestedDB := Change{Action: "Modify", Type: db, LogicalID: "Database", Replacement: "Conditional", NestedStack: "Storage"}
+
+	tests := []struct {
+		name    string
+		changes []Change
+		allowed []string
+		want    []Change
+	}{
+		{
+			name:    "no changes",
+			changes: nil,
+			want:    []Change{},
+		},
+		{
+			name: "safe changes",
+			changes: []Change{
+				{Action: "Add", Type: db, LogicalID: "Replica"},
+				{Action: "Modify", Type: db, LogicalID: "Database", Replacement: "False"},
+				{Action: "Remove", Type: "AWS::SNS::Topic", LogicalID: "Topic"},
+			},
+			want: []Change{},
+		},
+		{
+			name:    "replace and remove protected",
+			changes: []Change{replaceDB, removeDB},
+			want:    []Change{replaceDB, removeDB},
+		},
+		{
+			name:    "nested stack",
+			changes: []Change{nestedDB},
+			want:    []Change{nestedDB},
+		},
+		{
+			name:    "allowed by logical ID",
+			changes: []Change{replaceDB},
+			allowed: []string{"Database"},
+			want:    []Change{},
+		},
+		{
+			name:    "allowed by type",
+			changes: []Change{replaceDB, nestedDB},
+			allowed: []string{db},
+			want:    []Change{},
+		},
+		{
+			name:    "other resource allowed",
+			changes: []Change{replaceDB},
+			allowed: []string{"Cache"},
+			want:    []Change{replaceDB},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DestructiveChanges(tt.changes, protected, tt.allowed)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DestructiveChanges() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDestructive(t *testing.T) {
+	stack := &config.StackConfig{Name: "stack"}
+	changes := []Change{
+		{Action: "Remove", Type: "AWS::RDS::DBInstance", LogicalID: "Database"},
+		{Action: "Modify", Type: "AWS::RDS::DBInstance", LogicalID: "Database", Replacement: "True", NestedStack: "Storage"},
+	}
+
+	err := CheckDestructive(changes, []string{"AWS::RDS::DBInstance"}, stack)
+	if err == nil {
+		t.Fatal("CheckDestructive() = nil, want an error")
+	}
+	for _, want := range []string{
+		"would remove protected resource Database (AWS::RDS::DBInstance)",
+		"would replace protected resource Storage/Database (AWS::RDS::DBInstance)",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("CheckDestructive() = %q, missing %q", err, want)
+		}
+	}
+
+	if err := CheckDestructive(changes, nil, stack); err != nil {
+		t.Errorf("CheckDestructive() with nothing protected = %v, want nil", err)
+	}
+}
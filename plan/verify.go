@@ -0,0 +1,141 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/hashicorp/go-multierror"
+	"github.com/keyneston/cftool/config"
+)
+
+// updatableStatuses are the stack statuses from which a changeset can be
+// executed.
+var updatableStatuses = map[string]bool{
+	cloudformation.StackStatusCreateComplete:         true,
+	cloudformation.StackStatusUpdateComplete:         true,
+	cloudformation.StackStatusUpdateRollbackComplete: true,
+	cloudformation.StackStatusImportComplete:         true,
+	cloudformation.StackStatusImportRollbackComplete: true,
+}
+
+// Verify checks that nothing the plan was created from has changed since. It
+// returns an error listing every reason the plan for this stack can no longer
+// be trusted, along with the changes of the changeset as CloudFormation
+// currently describes it, including those of nested stacks.
+func (sp *StackPlan) Verify(ctx context.Context, stack *config.StackConfig) ([]Change, error) {
+	result := &multierror.Error{}
+
+	diskHash, err := stack.GetDiskTemplateHash()
+	if err != nil {
+		result = multierror.Append(result, err)
+	} else if diskHash != sp.DiskTemplateHash {
+		result = multierror.Append(result, fmt.Errorf("disk template %q has changed since the plan was created", stack.GetDiskTemplateLocation()))
+	}
+
+	liveHash, err := stack.GetLiveTemplateHash()
+	if err != nil {
+		result = multierror.Append(result, err)
+	} else if liveHash != sp.LiveTemplateHash {
+		result = multierror.Append(result, fmt.Errorf("live template has changed since the plan was created"))
+	}
+
+	for _, key := range config.ChangedParams(sp.Params, stack.Params) {
+		result = multierror.Append(result, fmt.Errorf("parameter %q has changed since the plan was created", key))
+	}
+
+	client, err := stack.GetClient()
+	if err != nil {
+		return nil, multierror.Append(result, err)
+	}
+
+	var changes []Change
+	changeSet, err := describeChangeSet(ctx, client, sp.ChangeSetARN)
+	if err != nil {
+		result = multierror.Append(result, fmt.Errorf("DescribeChangeSet: %v", err))
+	} else if status := aws.StringValue(changeSet.ExecutionStatus); status != cloudformation.ExecutionStatusAvailable {
+		result = multierror.Append(result, fmt.Errorf("changeset execution status is %s", status))
+	} else if changes, err = describeChanges(ctx, client, changeSet, ""); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	live, err := client.DescribeStacksWithContext(ctx, &cloudformation.DescribeStacksInput{
+		StackName: &sp.StackARN,
+	})
+	if err != nil {
+		result = multierror.Append(result, fmt.Errorf("DescribeStacks: %v", err))
+	} else if len(live.Stacks) == 0 {
+		result = multierror.Append(result, fmt.Errorf("stack %q not found", sp.StackARN))
+	} else if status := aws.StringValue(live.Stacks[0].StackStatus); !updatableStatuses[status] {
+		result = multierror.Append(result, fmt.Errorf("stack status is %s", status))
+	}
+
+	return changes, result.ErrorOrNil()
+}
+
+// describeChangeSet describes the changeset, following every page of changes.
+func describeChangeSet(ctx context.Context, client *cloudformation.CloudFormation, id string) (*cloudformation.DescribeChangeSetOutput, error) {
+	input := &cloudformation.DescribeChangeSetInput{ChangeSetName: &id}
+
+	out, err := client.DescribeChangeSetWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	for next := out.NextToken; next != nil; {
+		input.NextToken = next
+		page, err := client.DescribeChangeSetWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		out.Changes = append(out.Changes, page.Changes...)
+		next = page.NextToken
+	}
+
+	return out, nil
+}
+
+// describeChanges converts the resource changes of the changeset, fetching the
+// changesets of nested stacks and following each change to a nested stack
+// with its own changes.
+func describeChanges(ctx context.Context, client *cloudformation.CloudFormation, out *cloudformation.DescribeChangeSetOutput, nested string) ([]Change, error) {
+	changes := []Change{}
+
+	for _, c := range out.Changes {
+		rc := c.ResourceChange
+		if rc == nil {
+			continue
+		}
+
+		change := Change{
+			Action:      aws.StringValue(rc.Action),
+			Type:        aws.StringValue(rc.ResourceType),
+			LogicalID:   aws.StringValue(rc.LogicalResourceId),
+			Replacement: aws.StringValue(rc.Replacement),
+			NestedStack: nested,
+		}
+		changes = append(changes, change)
+
+		if rc.ChangeSetId == nil {
+			continue
+		}
+
+		child, err := describeChangeSet(ctx, client, aws.StringValue(rc.ChangeSetId))
+		if err != nil {
+			return nil, fmt.Errorf("fetching nested changeset for %s: %v", change.LogicalID, err)
+		}
+
+		childNested := change.LogicalID
+		if nested != "" {
+			childNested = nested + "/" + change.LogicalID
+		}
+		childChanges, err := describeChanges(ctx, client, child, childNested)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, childChanges...)
+	}
+
+	return changes, nil
+}